@@ -1,18 +1,58 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
 	"github.com/devetek/d-panel-cli/internal/api"
 	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"golang.org/x/term"
 )
 
+// state of session in current context
+type sessionRecord struct {
+	Context string `json:"context" yaml:"context"`
+	API     string `json:"api" yaml:"api"`
+	// token or session
+	Auth   string `json:"auth" yaml:"auth"`
+	Status string `json:"status" yaml:"status"`
+}
+
+type accountRecord struct {
+	ID       uint64 `json:"id" yaml:"id"`
+	Name     string `json:"name" yaml:"name"`
+	Username string `json:"username" yaml:"username"`
+	Email    string `json:"email" yaml:"email"`
+	Context  string `json:"context" yaml:"context"`
+	API      string `json:"api" yaml:"api"`
+	Auth     string `json:"auth" yaml:"auth"`
+	Session  string `json:"session" yaml:"session"`
+}
+
+// authentication method used by client
+func authMethod(client *api.Client) string {
+	if client.UsesToken() {
+		return "token"
+	}
+
+	return "session"
+}
+
 type AuthCmd struct {
 	cmd       *cobra.Command
 	zapLogger *zap.Logger
 
-	email    string
-	password string
+	email         string
+	password      string
+	passwordStdin bool
+	token         string
+	tokenStdin    bool
 }
 
 func NewAuthCmd(logger *zap.Logger) *AuthCmd {
@@ -28,6 +68,8 @@ func NewAuthCmd(logger *zap.Logger) *AuthCmd {
 func (u *AuthCmd) Connect() *cobra.Command {
 	u.cmd.AddCommand(
 		u.login(),
+		u.whoami(),
+		u.logout(),
 	)
 
 	return u.cmd
@@ -37,38 +79,238 @@ func (u *AuthCmd) login() *cobra.Command {
 	var runCmd = &cobra.Command{
 		Use:   "login",
 		Short: "Authorize to access dPanel",
-		Run: func(cmd *cobra.Command, args []string) {
-			if u.email == "" || u.password == "" {
-				logger.Error("Email and password are required")
-				return
-			}
+		Long: `Authorize to access dPanel with email and password, or with personal access token.
+
+Token and password given as flag value are visible in shell history and process list,
+use --token-stdin or --password-stdin instead. In CI, set DNOCS_TOKEN to use the token
+without login and without storing it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
 
 			// init dPanel client
 			client := api.NewClient()
 
+			// personal access token replace email and password
+			token, err := u.readToken()
+			if err != nil {
+				return validationError("%s", err)
+			}
+
+			if token != "" {
+				_, err := client.LoginTokenContext(ctx, token)
+				if err != nil {
+					return stepError(ctx, "validating token", err)
+				}
+
+				logger.Success("Success login to dPanel with personal access token!")
+				return u.printSession(client, authMethod(client), "active")
+			}
+
+			if u.email == "" {
+				return validationError("Email is required, or use --token to login with personal access token")
+			}
+
 			// check if session exist
-			err := client.CheckSessionExist()
+			err = client.CheckSessionExistContext(ctx)
 			if err != nil {
-				_, err := client.Login(u.email, u.password)
+				// only ask password when new session required
+				password, err := u.readPassword()
+				if err != nil {
+					return validationError("%s", err)
+				}
+
+				_, err = client.LoginContext(ctx, u.email, password)
 				if err != nil {
-					logger.Error("Login error: " + err.Error())
-					return
+					return stepError(ctx, "logging in", err)
 				}
 
 				// double check profile
-				_, err = client.GetProfile()
+				_, err = client.GetProfileContext(ctx)
 				if err != nil {
-					logger.Error("Error get user profile: " + err.Error())
-					return
+					return stepError(ctx, "getting user profile", err)
 				}
 			}
 
 			logger.Success("Success login to dPanel!")
+			return u.printSession(client, authMethod(client), "active")
 		},
 	}
 
 	runCmd.PersistentFlags().StringVarP(&u.email, "email", "e", "", "Youre registered email address in dPanel")
-	runCmd.PersistentFlags().StringVarP(&u.password, "password", "p", "", "Youre registered password in dPanel")
+	runCmd.PersistentFlags().StringVarP(&u.password, "password", "p", "", "Youre registered password in dPanel, prefer --password-stdin or prompt to keep it out of shell history")
+	runCmd.PersistentFlags().BoolVarP(&u.passwordStdin, "password-stdin", "", false, "Read password from stdin")
+	runCmd.PersistentFlags().StringVarP(&u.token, "token", "", "", "Login with personal access token instead of email and password, prefer --token-stdin or DNOCS_TOKEN to keep it out of shell history")
+	runCmd.PersistentFlags().BoolVarP(&u.tokenStdin, "token-stdin", "", false, "Read personal access token from stdin, prompt without echo in terminal")
+	runCmd.MarkFlagsMutuallyExclusive("token", "token-stdin")
+
+	return runCmd
+}
+
+// read password from flag, stdin, DNOCS_PASSWORD env variable, or prompt in order
+func (u *AuthCmd) readPassword() (string, error) {
+	if u.password != "" && u.passwordStdin {
+		return "", errors.New("--password and --password-stdin are mutually exclusive")
+	}
+
+	if u.password != "" {
+		return u.password, nil
+	}
+
+	if u.passwordStdin {
+		return readStdinSecret("password")
+	}
+
+	if password := os.Getenv("DNOCS_PASSWORD"); password != "" {
+		return password, nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("password is required, no terminal available to prompt, use --password-stdin or DNOCS_PASSWORD")
+	}
+
+	return promptSecret("Password")
+}
+
+// read personal access token from flag or stdin, empty when login with email and password
+func (u *AuthCmd) readToken() (string, error) {
+	if !u.tokenStdin {
+		return u.token, nil
+	}
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return promptSecret("Token")
+	}
+
+	return readStdinSecret("token")
+}
+
+// read secret piped to stdin, e.g. from password manager
+func readStdinSecret(name string) (string, error) {
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from stdin: %w", name, err)
+	}
+
+	secret := strings.TrimRight(string(content), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("%s from stdin is empty", name)
+	}
+
+	return secret, nil
+}
+
+// prompt secret in terminal without echo
+func promptSecret(label string) (string, error) {
+	// prompt to stderr, keep stdout clean for command output
+	fmt.Fprint(os.Stderr, label+": ")
+	content, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", strings.ToLower(label), err)
+	}
+
+	if len(content) == 0 {
+		return "", fmt.Errorf("%s is required", strings.ToLower(label))
+	}
+
+	return string(content), nil
+}
+
+func (u *AuthCmd) whoami() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "whoami",
+		Short: "Show account of current dPanel session",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// init dPanel client
+			client := api.NewClient()
+
+			if !client.HasSession() {
+				return newExitError(exitAuthRequired, "Session: not logged in, use command 'dnocs auth login' to login")
+			}
+
+			profile, err := client.GetProfileContext(ctx)
+			if err != nil {
+				if api.IsUnauthorized(err) {
+					return newExitError(exitAuthRequired, "Session: expired, use command 'dnocs auth login' to login again")
+				}
+
+				return stepError(ctx, "getting user profile", err)
+			}
+
+			return output.Print(accountRecord{
+				ID:       uint64(profile.Data.ID),
+				Name:     profile.Data.Fullname,
+				Username: profile.Data.Username,
+				Email:    profile.Data.Email,
+				Context:  client.Profile,
+				API:      client.BaseURL,
+				Auth:     authMethod(client),
+				Session:  "active",
+			})
+		},
+	}
+
+	return runCmd
+}
+
+func (u *AuthCmd) logout() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "logout",
+		Short: "End current dPanel session",
+		Long:  `End current dPanel session in the server if possible, and remove session from this machine.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// init dPanel client
+			client := api.NewClient()
+
+			if !client.HasSession() {
+				logger.Success("You are not logged in")
+				return u.printSession(client, authMethod(client), "logged_out")
+			}
+
+			if client.UsesEnvToken() {
+				return validationError("Token from DNOCS_TOKEN is used, unset the environment variable to logout")
+			}
+
+			// session can be already expired or server unreachable, local session still removed.
+			// personal access token is not revoked, manage it from dPanel dashboard
+			var err error
+			var method = authMethod(client)
+			if !client.UsesToken() {
+				err = client.LogoutContext(ctx)
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return stepError(ctx, "invalidating session in dPanel", err)
+				}
+
+				if !api.IsUnauthorized(err) {
+					logger.Normal("Unable to invalidate session in dPanel: " + err.Error())
+				}
+			}
+
+			err = client.ClearSession()
+			if err != nil {
+				return stepError(ctx, "removing local session", err)
+			}
+
+			logger.Success("Success logout from dPanel!")
+
+			return u.printSession(client, method, "logged_out")
+		},
+	}
 
 	return runCmd
 }
+
+func (u *AuthCmd) printSession(client *api.Client, method string, status string) error {
+	return output.Print(sessionRecord{
+		Context: client.Profile,
+		API:     client.BaseURL,
+		Auth:    method,
+		Status:  status,
+	})
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type contextRecord struct {
+	Current     bool   `json:"current" yaml:"current"`
+	Name        string `json:"name" yaml:"name"`
+	APIBaseURL  string `json:"api_base_url" yaml:"api_base_url"`
+	FrontendURL string `json:"frontend_url" yaml:"frontend_url"`
+	TunnelHost  string `json:"tunnel_host" yaml:"tunnel_host"`
+	TunnelPort  string `json:"tunnel_port" yaml:"tunnel_port"`
+	Gateway     string `json:"gateway_machine" yaml:"gateway_machine"`
+}
+
+func newContextRecord(cfg *api.Config, profile api.Profile) contextRecord {
+	return contextRecord{
+		Current:     profile.Name == cfg.ActiveProfileName(),
+		Name:        profile.Name,
+		APIBaseURL:  profile.APIBaseURL,
+		FrontendURL: profile.FrontendURL,
+		TunnelHost:  profile.TunnelHost,
+		TunnelPort:  profile.TunnelPort,
+		Gateway:     profile.GatewayMachine,
+	}
+}
+
+// print context after changed
+func printContext(cfg *api.Config, name string) error {
+	profile, err := cfg.Profile(name)
+	if err != nil {
+		return err
+	}
+
+	return output.Print(newContextRecord(cfg, *profile))
+}
+
+type ContextCmd struct {
+	cmd       *cobra.Command
+	zapLogger *zap.Logger
+
+	apiURL      string
+	frontendURL string
+	tunnelHost  string
+	tunnelPort  string
+	gateway     string
+	switchTo    bool
+}
+
+func NewContextCmd(logger *zap.Logger) *ContextCmd {
+	return &ContextCmd{
+		zapLogger: logger,
+		cmd: &cobra.Command{
+			Use:   "context",
+			Short: "Manage dPanel accounts and environments",
+			Long: `Manage named contexts, every context has its own API base URL, frontend URL, tunnel server and session.
+
+Use global flag --context to run a single command with another context.`,
+		},
+	}
+}
+
+func (c *ContextCmd) Connect() *cobra.Command {
+	c.cmd.AddCommand(
+		c.add(),
+		c.use(),
+		c.list(),
+		c.delete(),
+	)
+
+	return c.cmd
+}
+
+func (c *ContextCmd) add() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add new context",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := api.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("Error reading config: %w", err)
+			}
+
+			err = cfg.AddProfile(api.Profile{
+				Name:           args[0],
+				APIBaseURL:     c.apiURL,
+				FrontendURL:    c.frontendURL,
+				TunnelHost:     c.tunnelHost,
+				TunnelPort:     c.tunnelPort,
+				GatewayMachine: c.gateway,
+			})
+			if err != nil {
+				return validationError("%s", err)
+			}
+
+			if c.switchTo {
+				cfg.CurrentContext = args[0]
+			}
+
+			err = cfg.Save()
+			if err != nil {
+				return fmt.Errorf("Error saving config: %w", err)
+			}
+
+			logger.Success(fmt.Sprintf("Context %s added", args[0]))
+			return printContext(cfg, args[0])
+		},
+	}
+
+	runCmd.PersistentFlags().StringVarP(&c.apiURL, "api-url", "", "", "dPanel API base URL, e.g. https://pawon.terpusat.com")
+	runCmd.PersistentFlags().StringVarP(&c.frontendURL, "frontend-url", "", "", "dPanel frontend URL (optional)")
+	runCmd.PersistentFlags().StringVarP(&c.tunnelHost, "tunnel-host", "", "", "dPanel tunnel server host (optional)")
+	runCmd.PersistentFlags().StringVarP(&c.tunnelPort, "tunnel-port", "", "", "dPanel tunnel server port (optional)")
+	runCmd.PersistentFlags().StringVarP(&c.gateway, "gateway-machine", "", "", "ID or name of machine serving the tunnel host (optional)")
+	runCmd.PersistentFlags().BoolVarP(&c.switchTo, "use", "", false, "Switch to this context after added")
+
+	return runCmd
+}
+
+func (c *ContextCmd) use() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "use <name>",
+		Short: "Switch current context",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := api.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("Error reading config: %w", err)
+			}
+
+			err = cfg.UseProfile(args[0])
+			if err != nil {
+				return validationError("%s", err)
+			}
+
+			err = cfg.Save()
+			if err != nil {
+				return fmt.Errorf("Error saving config: %w", err)
+			}
+
+			logger.Success(fmt.Sprintf("Switched to context %s", args[0]))
+			return printContext(cfg, args[0])
+		},
+	}
+
+	return runCmd
+}
+
+func (c *ContextCmd) list() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List all context",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := api.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("Error reading config: %w", err)
+			}
+
+			var records []contextRecord
+			for _, profile := range cfg.Profiles() {
+				records = append(records, newContextRecord(cfg, profile))
+			}
+
+			return output.Print(records)
+		},
+	}
+
+	return runCmd
+}
+
+func (c *ContextCmd) delete() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete context and its session",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := api.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("Error reading config: %w", err)
+			}
+
+			profile, err := cfg.Profile(args[0])
+			if err != nil {
+				return validationError("%s", err)
+			}
+
+			err = cfg.DeleteProfile(args[0])
+			if err != nil {
+				return validationError("%s", err)
+			}
+
+			err = cfg.Save()
+			if err != nil {
+				return fmt.Errorf("Error saving config: %w", err)
+			}
+
+			logger.Success(fmt.Sprintf("Context %s deleted", args[0]))
+			return output.Print(newContextRecord(cfg, *profile))
+		},
+	}
+
+	return runCmd
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel-cli/internal/helper"
+	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
+	"github.com/devetek/d-panel-cli/internal/tunnel"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// result of doctor check
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+type checkRecord struct {
+	Check  string `json:"check" yaml:"check"`
+	Status string `json:"status" yaml:"status"`
+	Detail string `json:"detail" yaml:"detail"`
+}
+
+// time limit of every network check
+var doctorTimeout = 5 * time.Second
+
+type DoctorCmd struct {
+	cmd       *cobra.Command
+	zapLogger *zap.Logger
+
+	httpPort string
+	// minimum free disk space in GB
+	minDiskGB uint64
+}
+
+func NewDoctorCmd(logger *zap.Logger) *DoctorCmd {
+	return &DoctorCmd{
+		zapLogger: logger,
+		cmd: &cobra.Command{
+			Use:   "doctor",
+			Short: "Check if this machine is ready to be added to dPanel",
+			Long: `Run every check required by 'dnocs machine create', and report pass, warn or fail for each of them.
+Exit with code 9 when one of the checks failed.`,
+			Args: cobra.NoArgs,
+		},
+	}
+}
+
+func (d *DoctorCmd) Connect() *cobra.Command {
+	d.cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		// init dPanel client
+		client := api.NewClient()
+
+		var records []checkRecord
+		records = append(records, d.checkSudo())
+		records = append(records, d.checkSSHD()...)
+		records = append(records, d.checkHTTPPort())
+		records = append(records, d.checkSystemd())
+		records = append(records, d.checkDisk())
+
+		apiCheck, serverTime := d.checkAPI(ctx, client.BaseURL)
+		records = append(records, apiCheck, d.checkTunnel(ctx), d.checkClock(serverTime))
+
+		if ctx.Err() != nil {
+			return stepError(ctx, "running checks", ctx.Err())
+		}
+
+		err := output.Print(records)
+		if err != nil {
+			return err
+		}
+
+		var failed int
+		for _, record := range records {
+			if record.Status == checkFail {
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			return newExitError(exitCheckFailed, "%d of %d checks failed", failed, len(records))
+		}
+
+		logger.Success("This machine is ready to be added to dPanel")
+
+		return nil
+	}
+
+	d.cmd.PersistentFlags().StringVarP(&d.httpPort, "http-port", "p", "9000", "HTTP port of dPanel agent")
+	d.cmd.PersistentFlags().Uint64VarP(&d.minDiskGB, "min-disk", "", 5, "Minimum free disk space in GB")
+
+	return d.cmd
+}
+
+func (d *DoctorCmd) checkSudo() checkRecord {
+	var record = checkRecord{Check: "root or sudo", Status: checkPass, Detail: "running as root or with sudo access"}
+	if !helper.IsSudo() {
+		record.Status = checkFail
+		record.Detail = "run dnocs as root or with sudo, dPanel agent is running under root"
+	}
+
+	return record
+}
+
+// check sshd process, its port, and authentication method in sshd_config
+func (d *DoctorCmd) checkSSHD() []checkRecord {
+	var sshdConfig = helper.SSHDConfig{}
+	var configErr error
+	if config, err := helper.ReadSSHDConfig(helper.SSHDConfigPath); err != nil {
+		configErr = err
+	} else {
+		sshdConfig = config
+	}
+
+	var port = sshdConfig.Port()
+
+	var running = sshdRecord(port, helper.IsPortUsed("localhost", port), helper.IsProcessRunning("sshd"))
+
+	if configErr != nil {
+		return []checkRecord{running, {
+			Check:  "sshd_config",
+			Status: checkWarn,
+			Detail: fmt.Sprintf("failed to read %s: %s", helper.SSHDConfigPath, configErr),
+		}}
+	}
+
+	var pubkey = checkRecord{Check: "PubkeyAuthentication", Status: checkPass, Detail: "enabled, required by dPanel to access this machine"}
+	if strings.EqualFold(sshdConfig.Get("PubkeyAuthentication", "yes"), "no") {
+		pubkey.Status = checkFail
+		pubkey.Detail = "disabled, set 'PubkeyAuthentication yes' in " + helper.SSHDConfigPath
+	}
+
+	var password = checkRecord{Check: "PasswordAuthentication", Status: checkPass, Detail: "disabled"}
+	if !strings.EqualFold(sshdConfig.Get("PasswordAuthentication", "yes"), "no") {
+		password.Status = checkWarn
+		password.Detail = "enabled, consider 'PasswordAuthentication no' when the machine is exposed to internet"
+	}
+
+	return []checkRecord{running, pubkey, password}
+}
+
+// port is checked first, with systemd socket activation (default since Ubuntu 22.10)
+// sshd process only exist after the first connection
+func sshdRecord(port string, portReachable, processRunning bool) checkRecord {
+	var record = checkRecord{Check: "sshd", Status: checkPass, Detail: "running, listening on port " + port}
+	switch {
+	case portReachable && !processRunning:
+		record.Status = checkWarn
+		record.Detail = "port " + port + " is reachable but no sshd process found, expected when sshd is socket activated, it starts on the first connection"
+	case !portReachable && processRunning:
+		record.Status = checkWarn
+		record.Detail = "sshd is running, but port " + port + " from sshd_config is not reachable from localhost"
+	case !portReachable:
+		record.Status = checkFail
+		record.Detail = "sshd is not running and port " + port + " is not reachable, install and start OpenSSH server"
+	}
+
+	return record
+}
+
+func (d *DoctorCmd) checkHTTPPort() checkRecord {
+	var record = checkRecord{Check: "agent HTTP port", Status: checkPass, Detail: "port " + d.httpPort + " is free"}
+	if helper.IsPortUsed("localhost", d.httpPort) {
+		record.Status = checkFail
+		record.Detail = "port " + d.httpPort + " is used by other process"
+
+		if port, err := helper.FindAvailablePort(); err == nil {
+			record.Detail += fmt.Sprintf(", use --http-port=%d", port)
+		}
+	}
+
+	return record
+}
+
+// dPanel agent and tunnel installed as systemd service
+func (d *DoctorCmd) checkSystemd() checkRecord {
+	var record = checkRecord{Check: "systemd", Status: checkPass, Detail: "systemd is running"}
+	if _, err := os.Stat("/run/systemd/system"); err != nil {
+		record.Status = checkFail
+		record.Detail = "systemd is not running, dPanel agent and tunnel require systemd"
+	}
+
+	return record
+}
+
+func (d *DoctorCmd) checkDisk() checkRecord {
+	var record = checkRecord{Check: "disk space"}
+
+	free, err := helper.DiskFree("/")
+	if err != nil {
+		record.Status = checkWarn
+		record.Detail = err.Error()
+		return record
+	}
+
+	var freeGB = float64(free) / (1 << 30)
+	record.Detail = fmt.Sprintf("%.1f GB free on /", freeGB)
+
+	switch {
+	case freeGB < 1:
+		record.Status = checkFail
+	case freeGB < float64(d.minDiskGB):
+		record.Status = checkWarn
+		record.Detail += fmt.Sprintf(", %d GB recommended", d.minDiskGB)
+	default:
+		record.Status = checkPass
+	}
+
+	return record
+}
+
+// check dPanel API reachable, return server time from Date header to check clock skew
+func (d *DoctorCmd) checkAPI(ctx context.Context, baseURL string) (checkRecord, time.Time) {
+	var record = checkRecord{Check: "dPanel API", Status: checkPass, Detail: baseURL + " is reachable"}
+
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
+	if err != nil {
+		record.Status = checkFail
+		record.Detail = err.Error()
+		return record, time.Time{}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		record.Status = checkFail
+		record.Detail = fmt.Sprintf("%s is not reachable: %s", baseURL, err)
+		return record, time.Time{}
+	}
+	defer resp.Body.Close()
+
+	serverTime, _ := http.ParseTime(resp.Header.Get("Date"))
+
+	return record, serverTime
+}
+
+func (d *DoctorCmd) checkTunnel(ctx context.Context) checkRecord {
+	var address = net.JoinHostPort(tunnel.TunnelHost, tunnel.TunnelPort)
+	var record = checkRecord{Check: "tunnel host", Status: checkPass, Detail: address + " is reachable"}
+
+	dialer := net.Dialer{Timeout: doctorTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		// tunnel is only required for machine behind NAT
+		record.Status = checkWarn
+		record.Detail = fmt.Sprintf("%s is not reachable, required by --behind-tunnel: %s", address, err)
+		return record
+	}
+	conn.Close()
+
+	return record
+}
+
+// SSH key and session validation fail when the clock is far from dPanel
+func (d *DoctorCmd) checkClock(serverTime time.Time) checkRecord {
+	var record = checkRecord{Check: "clock skew"}
+
+	if serverTime.IsZero() {
+		record.Status = checkWarn
+		record.Detail = "unknown, dPanel API did not return its time"
+		return record
+	}
+
+	skew := time.Since(serverTime).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	record.Detail = fmt.Sprintf("%s from dPanel", skew)
+
+	switch {
+	case skew > 5*time.Minute:
+		record.Status = checkFail
+		record.Detail += ", sync the clock with NTP"
+	case skew > 30*time.Second:
+		record.Status = checkWarn
+		record.Detail += ", sync the clock with NTP"
+	default:
+		record.Status = checkPass
+	}
+
+	return record
+}
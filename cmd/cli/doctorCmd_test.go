@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSSHDRecord(t *testing.T) {
+	tests := []struct {
+		name           string
+		portReachable  bool
+		processRunning bool
+		wantStatus     string
+		wantDetail     string
+	}{
+		{name: "running", portReachable: true, processRunning: true, wantStatus: checkPass, wantDetail: "listening on port 22"},
+		{name: "socket activated", portReachable: true, wantStatus: checkWarn, wantDetail: "socket activated"},
+		{name: "port not reachable", processRunning: true, wantStatus: checkWarn, wantDetail: "not reachable from localhost"},
+		{name: "not running", wantStatus: checkFail, wantDetail: "install and start OpenSSH server"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sshdRecord("22", tt.portReachable, tt.processRunning)
+			if got.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", got.Status, tt.wantStatus)
+			}
+			if !strings.Contains(got.Detail, tt.wantDetail) {
+				t.Errorf("Detail = %q, want %q", got.Detail, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestExitCodeCheckFailed(t *testing.T) {
+	err := newExitError(exitCheckFailed, "%d of %d checks failed", 1, 10)
+	if exitCode(err) != 9 {
+		t.Errorf("exit code = %d, want 9", exitCode(err))
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+)
+
+// exit codes of dnocs, keep in sync with README
+const (
+	exitOK = 0
+	// unclassified error
+	exitGeneral = 1
+	// invalid flag, argument or input rejected by dPanel
+	exitValidation = 2
+	// no session, or session expired
+	exitAuthRequired = 3
+	// dPanel API returned an error
+	exitAPI = 4
+	// dPanel or other remote service unreachable
+	exitNetwork = 5
+	// command require root or sudo, or file not accessible
+	exitPermission = 6
+	// some steps succeeded, but the command did not finish
+	exitPartial = 7
+	// timed out waiting for dPanel, e.g. machine setup
+	exitTimeout = 8
+	// one or more checks of 'dnocs doctor' failed
+	exitCheckFailed = 9
+	// canceled by Ctrl+C or SIGTERM
+	exitInterrupted = 130
+)
+
+// exitError is an error with explicit exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func newExitError(code int, format string, args ...any) error {
+	return &exitError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+func validationError(format string, args ...any) error {
+	return newExitError(exitValidation, format, args...)
+}
+
+func permissionError(format string, args ...any) error {
+	return newExitError(exitPermission, format, args...)
+}
+
+func partialError(format string, args ...any) error {
+	return newExitError(exitPartial, format, args...)
+}
+
+func loginRequiredError() error {
+	return newExitError(exitAuthRequired, "Please login to your dPanel account, use command 'dnocs auth login --email=\"email@email.com\"'")
+}
+
+// exitCode classify error into exit code
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var codeErr *exitError
+	if errors.As(err, &codeErr) {
+		return codeErr.code
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return exitInterrupted
+	}
+
+	if api.IsUnauthorized(err) {
+		return exitAuthRequired
+	}
+
+	if api.IsValidation(err) {
+		return exitValidation
+	}
+
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) {
+		return exitAPI
+	}
+
+	if errors.Is(err, os.ErrPermission) {
+		return exitPermission
+	}
+
+	// syscall.Errno also implement net.Error, e.g. reading missing file is not a network error
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if _, ok := netErr.(syscall.Errno); !ok {
+			return exitNetwork
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return exitNetwork
+	}
+
+	return exitGeneral
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+)
+
+func TestExitCode(t *testing.T) {
+	_, missingErr := os.ReadFile(filepath.Join(t.TempDir(), "missing"))
+	_, refusedErr := net.Dial("tcp", "127.0.0.1:1")
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: exitOK},
+		{name: "unclassified", err: errors.New("boom"), want: exitGeneral},
+		{name: "explicit code", err: fmt.Errorf("wrapped: %w", partialError("half done")), want: exitPartial},
+		{name: "canceled", err: fmt.Errorf("request: %w", context.Canceled), want: exitInterrupted},
+		{name: "deadline", err: context.DeadlineExceeded, want: exitNetwork},
+		{name: "unauthorized", err: &api.APIError{StatusCode: http.StatusUnauthorized}, want: exitAuthRequired},
+		{name: "validation", err: &api.APIError{StatusCode: http.StatusBadRequest}, want: exitValidation},
+		{name: "server error", err: &api.APIError{StatusCode: http.StatusInternalServerError}, want: exitAPI},
+		{name: "connection refused", err: &url.Error{Op: "Get", URL: "http://127.0.0.1:1", Err: refusedErr}, want: exitNetwork},
+		{name: "dial error", err: refusedErr, want: exitNetwork},
+		{name: "permission", err: fmt.Errorf("writing: %w", os.ErrPermission), want: exitPermission},
+		{name: "missing file is not a network error", err: fmt.Errorf("Error reading private key: %w", missingErr), want: exitGeneral},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
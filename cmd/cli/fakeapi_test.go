@@ -0,0 +1,431 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel/pkg/dmachine"
+	"github.com/devetek/d-panel/pkg/drouter"
+	"github.com/devetek/d-panel/pkg/dsecret"
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeDPanel serve the part of dPanel API used by machine, router and secret commands, and record every request
+type fakeDPanel struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	servers  []dmachine.ResponseForPrivate
+	requests []string
+	// next status of server returned by detail, one per request, the last one is kept
+	statuses map[uint64][]string
+	// logs of server, filtered by after query
+	logs    map[uint64][]api.ServerLog
+	routers []drouter.ResponseRouter
+	secrets []dsecret.Response
+	// body of the last request, keyed by method and path
+	bodies map[string][]byte
+	// status replied to request with method and path, instead of handling it
+	failures map[string]int
+}
+
+// start fake dPanel, the client created by api.NewClient use it with a token session,
+// and read configuration from an empty home folder
+func newFakeDPanel(t *testing.T, servers ...dmachine.ResponseForPrivate) *fakeDPanel {
+	t.Helper()
+
+	fake := &fakeDPanel{servers: servers, bodies: map[string][]byte{}, failures: map[string]int{}, statuses: map[uint64][]string{}, logs: map[uint64][]api.ServerLog{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/user/profile", func(w http.ResponseWriter, r *http.Request) {
+		fake.reply(w, http.StatusOK, map[string]any{"email": "user@example.com"})
+	})
+	mux.HandleFunc("GET /api/v1/server/detail/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if server := fake.server(id); server != nil {
+			fake.reply(w, http.StatusOK, server)
+			return
+		}
+		fake.reply(w, http.StatusNotFound, nil)
+	})
+	mux.HandleFunc("GET /api/v1/server/logs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if fake.server(id) == nil {
+			fake.reply(w, http.StatusNotFound, nil)
+			return
+		}
+
+		after, _ := strconv.ParseUint(r.URL.Query().Get("after"), 10, 64)
+
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		var logs = []api.ServerLog{}
+		for _, log := range fake.logs[id] {
+			if log.ID > after {
+				logs = append(logs, log)
+			}
+		}
+		fake.reply(w, http.StatusOK, api.ServerLogList{Logs: logs})
+	})
+	mux.HandleFunc("DELETE /api/v1/server/delete/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		for i, server := range fake.servers {
+			if strconv.FormatUint(server.GetUint64ID(), 10) == r.PathValue("id") {
+				fake.servers = append(fake.servers[:i], fake.servers[i+1:]...)
+				fake.reply(w, http.StatusOK, nil)
+				return
+			}
+		}
+		fake.reply(w, http.StatusNotFound, nil)
+	})
+	mux.HandleFunc("PUT /api/v1/server/update/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		var payload dmachine.Payload
+		_ = json.Unmarshal(fake.bodies["PUT "+r.URL.Path], &payload)
+
+		for i, server := range fake.servers {
+			if strconv.FormatUint(server.GetUint64ID(), 10) != r.PathValue("id") {
+				continue
+			}
+
+			secretID, err := strconv.ParseUint(payload.SecretID, 10, 64)
+			if err != nil {
+				fake.reply(w, http.StatusBadRequest, nil)
+				return
+			}
+
+			fake.servers[i].SecretID = uint(secretID)
+			fake.reply(w, http.StatusOK, fake.servers[i])
+			return
+		}
+		fake.reply(w, http.StatusNotFound, nil)
+	})
+	mux.HandleFunc("GET /api/v1/server/find", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		fake.reply(w, http.StatusOK, map[string]any{
+			"servers":    fake.servers,
+			"pagination": map[string]int{"page": 1, "limit": 100, "total_item": len(fake.servers), "total_page": 1},
+		})
+	})
+	mux.HandleFunc("GET /api/v1/router/find", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		routers, pagination := paginate(fake.routers, r)
+		fake.reply(w, http.StatusOK, map[string]any{"routers": routers, "pagination": pagination})
+	})
+	mux.HandleFunc("GET /api/v1/router/detail/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		if i := fake.router(r.PathValue("id")); i >= 0 {
+			fake.reply(w, http.StatusOK, fake.routers[i])
+			return
+		}
+		fake.reply(w, http.StatusNotFound, nil)
+	})
+	mux.HandleFunc("DELETE /api/v1/router/delete/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		if i := fake.router(r.PathValue("id")); i >= 0 {
+			fake.routers = append(fake.routers[:i], fake.routers[i+1:]...)
+			fake.reply(w, http.StatusOK, nil)
+			return
+		}
+		fake.reply(w, http.StatusNotFound, nil)
+	})
+	mux.HandleFunc("POST /api/v1/router/create", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		var payload drouter.PayloadRouter
+		_ = json.Unmarshal(fake.bodies["POST /api/v1/router/create"], &payload)
+
+		for _, router := range fake.routers {
+			if router.Domain == payload.Domain {
+				fake.reply(w, http.StatusBadRequest, nil)
+				return
+			}
+		}
+
+		var router = drouter.ResponseRouter{
+			ID:        uint(100 + len(fake.routers)),
+			Name:      payload.Name,
+			Type:      payload.Type,
+			Domain:    payload.Domain,
+			MachineID: payload.MachineID,
+			Upstream:  payload.Upstream,
+		}
+		fake.routers = append(fake.routers, router)
+		fake.reply(w, http.StatusOK, router)
+	})
+	mux.HandleFunc("GET /api/v1/secret/ssh-key/find", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		// list does not contain the key
+		var secrets []dsecret.Response
+		for _, secret := range fake.secrets {
+			secrets = append(secrets, dsecret.Response{ID: secret.ID, Name: secret.Name, Type: secret.Type})
+		}
+
+		secrets, pagination := paginate(secrets, r)
+		fake.reply(w, http.StatusOK, map[string]any{"secrets": secrets, "pagination": pagination})
+	})
+	mux.HandleFunc("GET /api/v1/secret/ssh-key/detail/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		if i := fake.secret(r.PathValue("id")); i >= 0 {
+			fake.reply(w, http.StatusOK, fake.secrets[i])
+			return
+		}
+		fake.reply(w, http.StatusNotFound, nil)
+	})
+	mux.HandleFunc("POST /api/v1/secret/ssh-key/create", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		var payload dsecret.Payload
+		_ = json.Unmarshal(fake.bodies["POST /api/v1/secret/ssh-key/create"], &payload)
+
+		publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+		sshKey, _ := ssh.NewPublicKey(publicKey)
+		block, _ := ssh.MarshalPrivateKey(privateKey, "")
+
+		fake.reply(w, http.StatusOK, fake.addSecret(payload.Name, map[string]string{
+			"public":  string(ssh.MarshalAuthorizedKey(sshKey)),
+			"private": string(pem.EncodeToMemory(block)),
+		}))
+	})
+	mux.HandleFunc("POST /api/v1/secret/ssh-key/import", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		var payload api.ImportSecretSSHPayload
+		_ = json.Unmarshal(fake.bodies["POST /api/v1/secret/ssh-key/import"], &payload)
+
+		fake.reply(w, http.StatusOK, fake.addSecret(payload.Name, map[string]string{"public": payload.PublicKey, "private": payload.PrivateKey}))
+	})
+	mux.HandleFunc("DELETE /api/v1/secret/ssh-key/delete/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		if i := fake.secret(r.PathValue("id")); i >= 0 {
+			fake.secrets = append(fake.secrets[:i], fake.secrets[i+1:]...)
+			fake.reply(w, http.StatusOK, nil)
+			return
+		}
+		fake.reply(w, http.StatusNotFound, nil)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fake.reply(w, http.StatusNotFound, nil)
+	})
+
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		fake.mu.Lock()
+		fake.requests = append(fake.requests, r.Method+" "+r.URL.Path)
+		fake.bodies[r.Method+" "+r.URL.Path] = body
+		status, failed := fake.failures[r.Method+" "+r.URL.Path]
+		fake.mu.Unlock()
+
+		if failed {
+			fake.reply(w, status, nil)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(fake.Close)
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("DNOCS_API_BASE_URL", fake.URL)
+	t.Setenv("DNOCS_TOKEN", "test-token")
+	t.Setenv("DNOCS_API_RETRY_MAX", "1")
+	t.Setenv("DNOCS_SESSION_STORE", "")
+
+	return fake
+}
+
+func (f *fakeDPanel) server(id uint64) *dmachine.ResponseForPrivate {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, server := range f.servers {
+		if server.GetUint64ID() != id {
+			continue
+		}
+
+		if next := f.statuses[id]; len(next) > 0 {
+			f.servers[i].Status = next[0]
+			if len(next) > 1 {
+				f.statuses[id] = next[1:]
+			}
+		}
+
+		server = f.servers[i]
+		return &server
+	}
+
+	return nil
+}
+
+// index of router with ID, -1 when not found, mu must be locked
+func (f *fakeDPanel) router(id string) int {
+	for i, router := range f.routers {
+		if strconv.FormatUint(uint64(router.ID), 10) == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// index of secret with ID, -1 when not found, mu must be locked
+func (f *fakeDPanel) secret(id string) int {
+	for i, secret := range f.secrets {
+		if strconv.FormatUint(uint64(secret.ID), 10) == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// add secret with the next ID, mu must be locked
+func (f *fakeDPanel) addSecret(name string, data map[string]string) dsecret.Response {
+	var id uint = 1
+	for _, secret := range f.secrets {
+		id = max(id, secret.ID+1)
+	}
+
+	var secret = newTestSecretData(id, name, data)
+	f.secrets = append(f.secrets, secret)
+
+	return secret
+}
+
+// secret ssh as decoded from dPanel response, key data is only set through JSON
+func newTestSecret(id uint, name, publicKey string) dsecret.Response {
+	return newTestSecretData(id, name, map[string]string{"public": publicKey})
+}
+
+// secret ssh with public and private key data
+func newTestSecretData(id uint, name string, data map[string]string) dsecret.Response {
+	var secret dsecret.Response
+	jsonByte, _ := json.Marshal(map[string]any{
+		"id":   id,
+		"name": name,
+		"type": "ssh-key",
+		"data": data,
+	})
+	_ = json.Unmarshal(jsonByte, &secret)
+
+	return secret
+}
+
+// page of items selected by page and limit query, limit default to 20
+func paginate[T any](items []T, r *http.Request) ([]T, map[string]int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	start := min((page-1)*limit, len(items))
+	end := min(start+limit, len(items))
+
+	return append([]T{}, items[start:end]...), map[string]int{
+		"page":       page,
+		"limit":      limit,
+		"total_item": len(items),
+		"total_page": (len(items) + limit - 1) / limit,
+	}
+}
+
+// reply with status to every request with method and path, e.g. "PUT /api/v1/server/update/1"
+func (f *fakeDPanel) fail(request string, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures[request] = status
+}
+
+// set status returned by the next detail requests of the server
+func (f *fakeDPanel) setStatuses(id uint64, statuses ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.statuses[id] = statuses
+}
+
+// append logs of server, returned by the next logs requests
+func (f *fakeDPanel) addLogs(id uint64, logs ...api.ServerLog) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.logs[id] = append(f.logs[id], logs...)
+}
+
+// number of request with method and path received
+func (f *fakeDPanel) count(request string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var total int
+	for _, r := range f.requests {
+		if r == request {
+			total++
+		}
+	}
+
+	return total
+}
+
+// reply with dPanel response envelope
+func (f *fakeDPanel) reply(w http.ResponseWriter, status int, data any) {
+	var body = map[string]any{"code": status, "data": data}
+	if status != http.StatusOK {
+		body["error"] = http.StatusText(status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// check if request with method and path was received
+func (f *fakeDPanel) received(request string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, r := range f.requests {
+		if r == request {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+)
+
+// wrap error of failed step, when the step is canceled by user (Ctrl+C)
+// tell which step was interrupted, so user know the last state in dPanel
+func stepError(ctx context.Context, step string, err error) error {
+	if ctx.Err() != nil {
+		return newExitError(exitInterrupted, "Interrupted while %s", step)
+	}
+
+	return fmt.Errorf("Error %s: %w", step, err)
+}
+
+// check session before calling dPanel API, return login required error when session not exist or expired
+func requireSession(ctx context.Context, client *api.Client) error {
+	err := client.CheckSessionExistContext(ctx)
+	if err == nil {
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return stepError(ctx, "checking session", err)
+	}
+
+	// dPanel unreachable is not a login problem
+	if code := exitCode(err); code == exitNetwork || code == exitAPI {
+		return stepError(ctx, "checking session", err)
+	}
+
+	return loginRequiredError()
+}
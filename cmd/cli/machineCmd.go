@@ -1,21 +1,146 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os/user"
-	"strings"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/devetek/d-panel-cli/internal/api"
 	"github.com/devetek/d-panel-cli/internal/helper"
 	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
 	"github.com/devetek/d-panel-cli/internal/tunnel"
 	"github.com/devetek/d-panel/pkg/dmachine"
-	"github.com/devetek/d-panel/pkg/drouter"
-	"github.com/devetek/d-panel/pkg/dsecret"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
+type machineRecord struct {
+	ID       uint64 `json:"id" yaml:"id"`
+	Provider string `json:"provider" yaml:"provider"`
+	Address  string `json:"address" yaml:"address"`
+	SSHPort  string `json:"ssh_port" yaml:"ssh_port"`
+	HTTPPort string `json:"http_port" yaml:"http_port"`
+	Domain   string `json:"domain" yaml:"domain"`
+	SSHUser  string `json:"ssh_user" yaml:"ssh_user"`
+	SecretID string `json:"secret_id" yaml:"secret_id"`
+	Status   string `json:"status" yaml:"status"`
+	URL      string `json:"url" yaml:"url"`
+}
+
+func newMachineRecord(server dmachine.ResponseForPrivate, frontendURL string) machineRecord {
+	return machineRecord{
+		ID:       uint64(server.ID),
+		Provider: server.Provider,
+		Address:  server.Address,
+		SSHPort:  fmt.Sprint(server.SSHPort),
+		HTTPPort: fmt.Sprint(server.HTTPPort),
+		Domain:   server.Domain,
+		SSHUser:  server.SSHUser,
+		SecretID: fmt.Sprint(server.SecretID),
+		Status:   fmt.Sprint(server.Status),
+		URL:      frontendURL + "/v2/resources/servers",
+	}
+}
+
+// find server by ID, or by name (domain or address) from list server
+func findServer(ctx context.Context, client *api.Client, ref string) (*dmachine.ResponseForPrivate, error) {
+	if serverID, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		server, err := client.GetServerContext(ctx, serverID)
+		if err != nil {
+			if api.IsNotFound(err) {
+				return nil, validationError("Machine %s not found", ref)
+			}
+			return nil, stepError(ctx, "getting machine "+ref, err)
+		}
+
+		return &server.Data, nil
+	}
+
+	for page := 1; ; page++ {
+		servers, err := client.ListServersContext(ctx, api.ListServerOptions{Page: page, Limit: 100})
+		if err != nil {
+			return nil, stepError(ctx, "getting list machine", err)
+		}
+
+		for _, server := range servers.Data.Servers {
+			if trimScheme(server.Domain) == trimScheme(ref) || server.Address == ref {
+				return &server, nil
+			}
+		}
+
+		if len(servers.Data.Servers) == 0 || page >= servers.Data.Pagination.TotalPage {
+			break
+		}
+	}
+
+	return nil, validationError("Machine %s not found", ref)
+}
+
+type machineStatusRecord struct {
+	Registered bool   `json:"registered" yaml:"registered"`
+	ID         uint64 `json:"id,omitempty" yaml:"id,omitempty"`
+	Address    string `json:"address,omitempty" yaml:"address,omitempty"`
+	SSHPort    string `json:"ssh_port,omitempty" yaml:"ssh_port,omitempty"`
+	HTTPPort   string `json:"http_port,omitempty" yaml:"http_port,omitempty"`
+	Domain     string `json:"domain,omitempty" yaml:"domain,omitempty"`
+	SSHUser    string `json:"ssh_user,omitempty" yaml:"ssh_user,omitempty"`
+	// status in dPanel, unknown when dPanel not reachable
+	Status string `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+type machineDeleteRecord struct {
+	ID            uint64 `json:"id" yaml:"id"`
+	Deleted       bool   `json:"deleted" yaml:"deleted"`
+	KeyRemoved    bool   `json:"key_removed" yaml:"key_removed"`
+	RecordRemoved bool   `json:"record_removed" yaml:"record_removed"`
+	// sudoers drop-in written by machine create --grant-sudo
+	SudoersRemoved bool   `json:"sudoers_removed" yaml:"sudoers_removed"`
+	RouterID       uint64 `json:"router_id,omitempty" yaml:"router_id,omitempty"`
+}
+
+// get server registered from this machine, nil when not registered or the server no longer exist in dPanel
+func registeredMachine(ctx context.Context, client *api.Client) (*dmachine.ResponseForPrivate, error) {
+	machine, err := api.ReadMachine()
+	if err != nil {
+		return nil, stepError(ctx, "reading ~/.devetek/machine.json", err)
+	}
+
+	if machine == nil {
+		return nil, nil
+	}
+
+	server, err := client.GetServerContext(ctx, machine.GetUint64ID())
+	if err != nil {
+		if api.IsNotFound(err) {
+			logger.Normal(fmt.Sprintf("Machine %d in ~/.devetek/machine.json no longer exist in dPanel", machine.ID))
+			return nil, nil
+		}
+
+		// do not assume not registered when dPanel unreachable, it can register duplicate server
+		return nil, stepError(ctx, fmt.Sprintf("getting machine %d", machine.ID), err)
+	}
+
+	return &server.Data, nil
+}
+
+// machine from argument, or the machine registered from this machine, empty when both not exist
+func machineRef(args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+
+	machine, err := api.ReadMachine()
+	if err != nil || machine == nil {
+		return "", err
+	}
+
+	return strconv.FormatUint(machine.GetUint64ID(), 10), nil
+}
+
 type MachineCmd struct {
 	cmd       *cobra.Command
 	zapLogger *zap.Logger
@@ -23,6 +148,29 @@ type MachineCmd struct {
 	sshIP    string
 	sshPort  string
 	httpPort string
+
+	// list filter and pagination
+	page         int
+	limit        int
+	statusFilter string
+	provider     string
+
+	// wait for setup to finish
+	wait         bool
+	follow       bool
+	waitTimeout  time.Duration
+	waitInterval time.Duration
+	logInterval  time.Duration
+
+	// rotate-key option
+	keyName    string
+	keySize    int
+	skipVerify bool
+
+	// delete option
+	local        bool
+	deleteRouter bool
+
 	// use behind tunnel if this machine is behind NAT without public IP
 	behindTunnel bool
 	// used when your machine want to expose dPanel agent behind proxy
@@ -30,6 +178,18 @@ type MachineCmd struct {
 	// - http://my-machine-01.devetek.app -> for insecure connection / HTTP
 	// - https://my-machine-01.devetek.app -> for Secure connection / HTTPS
 	domain string
+	// SSH key used by dPanel to access this machine, default to the first key in the account
+	secretID   string
+	secretName string
+	// user used by dPanel to login to this machine, default to current user
+	sshUser string
+	// allow sshUser to run any command as root without password, with sudoers drop-in
+	grantSudo bool
+	// source IP or CIDR allowed to use the SSH key, written as from= option in authorized_keys
+	allowFrom []string
+
+	// machine in dPanel which route HTTP request from tunnel host to this machine
+	gatewayMachine string
 }
 
 func NewMachineCmd(logger *zap.Logger) *MachineCmd {
@@ -45,6 +205,14 @@ func NewMachineCmd(logger *zap.Logger) *MachineCmd {
 func (m *MachineCmd) Connect() *cobra.Command {
 	m.cmd.AddCommand(
 		m.create(),
+		m.list(),
+		m.get(),
+		m.delete(),
+		m.status(),
+		m.forget(),
+		m.waitCmd(),
+		m.logs(),
+		m.rotateKey(),
 	)
 
 	return m.cmd
@@ -55,73 +223,82 @@ func (m *MachineCmd) create() *cobra.Command {
 		Use:   "create",
 		Short: "Add this machine to dPanel",
 		Long:  `Add this machine to dPanel and manage easily.`,
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if (m.wait || m.follow) && (m.waitTimeout <= 0 || m.waitInterval <= 0) {
+				return validationError("--timeout and --interval must be greater than 0")
+			}
+
 			// check if user has sudo access in golang
 			if !helper.IsSudo() {
-				logger.Error("You must run this command as sudo, currenty dpanel-agent required to running under root")
-				return
+				return permissionError("You must run this command as sudo, currenty dpanel-agent required to running under root")
+			}
+
+			if m.grantSudo && m.sshUser == "" {
+				return validationError("--grant-sudo is only used with --ssh-user")
+			}
+
+			if m.sshUser != "" {
+				if err := helper.ValidateUsername(m.sshUser); err != nil {
+					return validationError("Invalid --ssh-user: %s", err)
+				}
+
+				// creating user and writing sudoers.d require root, sudo access is not enough
+				if os.Geteuid() != 0 {
+					return permissionError("--ssh-user require root to create the user and its sudoers drop-in, run this command with sudo")
+				}
 			}
 
 			// init dPanel client
 			client := api.NewClient()
 
 			// check if session exist
-			err := client.CheckSessionExist()
+			err := requireSession(ctx, client)
 			if err != nil {
-				logger.Error("Please login to your dPanel account, use command 'dnocs auth login --email=\"email@email.com\" --password=\"password\"'")
-				return
+				return err
 			}
 
-			// get list secret ssh
-			secretSSH, err := client.GetListSecretSSH()
+			// check if server already registered, before creating secret or router for the new server
+			registered, err := registeredMachine(ctx, client)
 			if err != nil {
-				logger.Error("Error get list secret ssh: " + err.Error())
-				return
+				return err
+			}
+			if registered != nil {
+				return validationError("This machine already registered as machine %d, use 'dnocs machine status' to check it", registered.ID)
 			}
 
-			var mySSHKey dsecret.Response
-			if secretSSH.Data.Pagination.TotalItem == 0 {
-				// create new SSH key
-				newSSHKey, err := client.CreateSecretSSH()
-				if err != nil {
-					logger.Error("Error create secret ssh: " + err.Error())
-					return
-				}
-
-				// assign from new SSH key
-				mySSHKey = newSSHKey.Data
-			} else {
-				// get first secret ssh from existing
-				mySSHKey = secretSSH.Data.Secrets[0]
+			// user used by dPanel to login to this machine
+			sshAccount, err := prepareSSHUser(ctx, m.sshUser, m.grantSudo)
+			if err != nil {
+				return err
+			}
 
-				// get detail secret ssh
-				detailSSHKey, err := client.GetSecretSSHByID(fmt.Sprintf("%d", mySSHKey.ID))
-				if err != nil {
-					logger.Error("Error get detail secret ssh: " + err.Error())
-					return
-				}
+			mySSHKey, err := selectSecretSSH(ctx, client, m.secretID, m.secretName)
+			if err != nil {
+				return err
+			}
 
-				// assign from detail SSH key
-				mySSHKey = detailSSHKey.Data
+			if mySSHKey.Data.Data()["public"] == "" {
+				return validationError("Secret ssh %d has no public key", mySSHKey.ID)
 			}
 
-			if !helper.IsSSHAuthorized(mySSHKey.Data.Data()["public"]) {
-				// append ssh key to authorized_keys file
-				err = helper.AppendAuthorizedKey(mySSHKey.Data.Data()["public"])
-				if err != nil {
-					logger.Error("Error append ssh key to authorized_keys file: " + err.Error())
-					return
-				}
+			// append ssh key to authorized_keys file, existing key is updated with --allow-from
+			added, err := helper.AuthorizeKey(sshAccount, mySSHKey.Data.Data()["public"], helper.AuthorizeOptions{From: m.allowFrom})
+			if err != nil {
+				return stepError(ctx, "appending ssh key to authorized_keys file", err)
+			}
+			if added {
+				logger.Success("dPanel SSH key added to " + helper.AuthorizedKeysPath(sshAccount))
 			}
 
 			if !m.behindTunnel {
 				// make sure sshIP is not empty
 				if m.sshIP == "" {
 					// get my public IP automatically
-					m.sshIP, err = helper.GetMyIP()
+					m.sshIP, err = helper.GetMyIPContext(ctx)
 					if err != nil {
-						logger.Error("Error get my public IP " + err.Error())
-						return
+						return stepError(ctx, "getting my public IP", err)
 					}
 				}
 
@@ -129,20 +306,13 @@ func (m *MachineCmd) create() *cobra.Command {
 					// get available port
 					availablePort, err := helper.FindAvailablePort()
 					if err != nil {
-						logger.Error("Error get available port + " + err.Error())
-						return
+						return stepError(ctx, "getting available port", err)
 					}
 
 					m.httpPort = fmt.Sprintf("%d", availablePort)
 				}
 			}
 
-			currentUser, err := user.Current()
-			if err != nil {
-				logger.Error("Error getting current user: " + err.Error())
-				return
-			}
-
 			// integrate with tunnel
 			if m.behindTunnel {
 				var currentTunnel = tunnel.NewTunnel()
@@ -150,43 +320,37 @@ func (m *MachineCmd) create() *cobra.Command {
 				// check tunnel configs
 				var tunnelConfig = currentTunnel.GetConfig()
 				if len(tunnelConfig) == 0 {
-					logger.Error("This machine is not connected to dPanel tunnel")
-					return
+					return validationError("This machine is not connected to dPanel tunnel, use command 'dnocs tunnel create' first")
 				}
 
-				var tunnelHTTPPort string
-				var originHTTPPort string
-				for _, tunnel := range tunnelConfig {
-					if strings.Contains(tunnel.ID, "ssh-") {
-						m.sshIP = tunnel.TunnelHost
-						m.sshPort = tunnel.ListenerPort
-					}
+				ports, err := readTunnelPorts(tunnelConfig)
+				if err != nil {
+					return err
+				}
 
-					if strings.Contains(tunnel.ID, "http-") {
-						tunnelHTTPPort = tunnel.ListenerPort
-						originHTTPPort = tunnel.ServicePort
-					}
+				m.sshIP = ports.sshHost
+				m.sshPort = ports.sshPort
+
+				gateway, err := resolveGateway(ctx, client, m.gatewayMachine)
+				if err != nil {
+					return err
 				}
 
-				// set payload
-				var payload = drouter.PayloadRouter{
-					AdvanceMode: false,
-					Type:        "proxy_pass",
-					Name:        fmt.Sprintf("http-%s-to-%s", tunnelHTTPPort, originHTTPPort),
-					Domain:      fmt.Sprintf("http-%s-to-%s 1", tunnelHTTPPort, originHTTPPort),
-					MachineID:   11,
-					Upstream:    fmt.Sprintf("localhost:%s", tunnelHTTPPort),
+				var domain = trimScheme(m.domain)
+				if domain == "" {
+					domain = tunnelRouterDomain(currentHostname(), ports.httpListenerPort, tunnel.TunnelHost)
+				}
+				if !isValidDomain(domain) {
+					return validationError("Invalid domain %q for HTTP router", domain)
 				}
 
-				router, err := client.CreateRouter(payload)
+				router, err := client.CreateRouterContext(ctx, tunnelRouterPayload(ports, gateway.ID, domain))
 				if err != nil {
-					logger.Error("Failed to create HTTP server for this machine, with error " + err.Error())
-					logger.Error("Login to dPanel, open https://cloud-beta.terpusat.com/router, and delete existing domain")
-					return
+					return routerCreateError(ctx, client, domain, err)
 				}
 
 				// set domain for this machine
-				m.httpPort = originHTTPPort
+				m.httpPort = ports.httpServicePort
 				m.domain = router.Data.Domain
 			}
 
@@ -198,30 +362,71 @@ func (m *MachineCmd) create() *cobra.Command {
 				SSHPort:  m.sshPort,
 				HTTPPort: m.httpPort,
 				Domain:   m.domain,
-				SSHUser:  currentUser.Username,
-			}
-
-			// check if server already registered
-			if client.IsRegistered() {
-				logger.Error("Server already registered with your account")
-				return
+				SSHUser:  sshAccount.Username,
 			}
 
 			// register new server
-			server, err := client.RegisterServer(newServer)
+			server, err := client.RegisterServerContext(ctx, newServer)
 			if err != nil {
-				logger.Error("Error register server " + err.Error())
-				return
+				return stepError(ctx, "registering server", err)
+			}
+
+			// keep registration record, so rerun will not register duplicate server.
+			// Setup is still started, the command fails after it because rerun can not detect this server.
+			saveErr := api.SaveMachine(server.Data)
+			if saveErr != nil {
+				logger.Error(fmt.Sprintf("Server %d registered, but failed to save ~/.devetek/machine.json: %s", server.Data.ID, saveErr))
 			}
 
 			// setup server
-			_, err = client.SetupServer(int(server.Data.ID))
+			_, err = client.SetupServerContext(ctx, int(server.Data.ID))
+			if err != nil {
+				if ctx.Err() != nil {
+					return stepError(ctx, fmt.Sprintf("setting up server %d", server.Data.ID), err)
+				}
+
+				return partialError("Server %d registered, but setup failed: %w", server.Data.ID, err)
+			}
+
+			if saveErr != nil {
+				if err := output.Print(newMachineRecord(server.Data, client.FrontendURL)); err != nil {
+					return err
+				}
+
+				return partialError("Server %d registered and setup started, but saving ~/.devetek/machine.json failed, do not rerun 'dnocs machine create' or it will register a duplicate: %w", server.Data.ID, saveErr)
+			}
+
+			// refresh registration record with the latest state after setup
+			detail, err := client.GetServerContext(ctx, server.Data.GetUint64ID())
+			if err == nil {
+				server.Data = detail.Data
+				_ = api.SaveMachine(server.Data)
+			}
+
+			if !m.wait && !m.follow {
+				logger.Success("Success register server, visit " + client.FrontendURL + "/v2/resources/servers to check the progress!")
+
+				return output.Print(newMachineRecord(server.Data, client.FrontendURL))
+			}
+
+			logger.Success(fmt.Sprintf("Success register server %d, waiting for setup to finish", server.Data.ID))
+
+			var logs *logFollower
+			if m.follow {
+				logs = &logFollower{client: client, serverID: server.Data.GetUint64ID(), print: printLogMessage}
+			}
+
+			ready, err := waitSetup(ctx, client, server.Data.GetUint64ID(), m.waitTimeout, m.waitInterval, logs)
 			if err != nil {
-				logger.Error("Error setup server " + err.Error())
-				return
+				return err
 			}
 
-			logger.Success("Success register server, visit " + api.FrontendURL + "/v2/resources/servers to check the progress!")
+			server.Data = *ready
+			_ = api.SaveMachine(server.Data)
+
+			logger.Success(fmt.Sprintf("Machine %d is ready", server.Data.ID))
+
+			return output.Print(newMachineRecord(server.Data, client.FrontendURL))
 		},
 	}
 
@@ -230,6 +435,460 @@ func (m *MachineCmd) create() *cobra.Command {
 	runCmd.PersistentFlags().StringVarP(&m.httpPort, "http-port", "p", "9000", "HTTP port of your machine")
 	runCmd.PersistentFlags().StringVarP(&m.domain, "http-domain", "d", "", "HTTP domain of agent (optional)")
 	runCmd.PersistentFlags().BoolVarP(&m.behindTunnel, "behind-tunnel", "t", false, "Read tunnel config and auto create domain")
+	runCmd.PersistentFlags().StringVarP(&m.secretID, "secret-id", "", "", "ID of SSH key used by dPanel to access this machine (optional)")
+	runCmd.PersistentFlags().StringVarP(&m.secretName, "secret-name", "", "", "Name of SSH key used by dPanel to access this machine (optional)")
+	runCmd.MarkFlagsMutuallyExclusive("secret-id", "secret-name")
+	runCmd.PersistentFlags().StringVarP(&m.sshUser, "ssh-user", "u", "", "User used by dPanel to login to this machine, created when not exist (default current user)")
+	runCmd.PersistentFlags().BoolVarP(&m.grantSudo, "grant-sudo", "", false, "Allow --ssh-user to run any command as root without password, required by dPanel setup, written to /etc/sudoers.d/dnocs-<user>")
+	runCmd.PersistentFlags().StringSliceVarP(&m.allowFrom, "allow-from", "", nil, "Only accept dPanel SSH key from this IP or CIDR, e.g. 203.0.113.10 (optional, repeatable)")
+	runCmd.PersistentFlags().StringVarP(&m.gatewayMachine, "gateway-machine", "", "", "ID or name of machine serving the tunnel host, used with --behind-tunnel (optional)")
+	runCmd.PersistentFlags().BoolVarP(&m.wait, "wait", "w", false, "Wait until setup of the machine finished")
+	runCmd.PersistentFlags().BoolVarP(&m.follow, "follow", "f", false, "Wait until setup of the machine finished, and print setup logs")
+	m.waitFlags(runCmd)
+
+	return runCmd
+}
+
+func (m *MachineCmd) list() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List machine in your dPanel account",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if m.page < 1 || m.limit < 1 {
+				return validationError("--page and --limit must be greater than 0")
+			}
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err := requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			servers, err := client.ListServersContext(ctx, api.ListServerOptions{
+				Page:     m.page,
+				Limit:    m.limit,
+				Status:   m.statusFilter,
+				Provider: m.provider,
+			})
+			if err != nil {
+				return stepError(ctx, "getting list machine", err)
+			}
+
+			var records []machineRecord
+			for _, server := range servers.Data.Servers {
+				records = append(records, newMachineRecord(server, client.FrontendURL))
+			}
+
+			err = output.Print(records)
+			if err != nil {
+				return err
+			}
+
+			var pagination = servers.Data.Pagination
+			if pagination.TotalPage > 1 {
+				logger.Normal(fmt.Sprintf("Page %d of %d, total %d machine, use --page to see other page", m.page, pagination.TotalPage, pagination.TotalItem))
+			}
+
+			return nil
+		},
+	}
+
+	runCmd.PersistentFlags().IntVarP(&m.page, "page", "", 1, "Page number")
+	runCmd.PersistentFlags().IntVarP(&m.limit, "limit", "", 20, "Number of machine per page")
+	runCmd.PersistentFlags().StringVarP(&m.statusFilter, "status", "", "", "Filter by machine status")
+	runCmd.PersistentFlags().StringVarP(&m.provider, "provider", "", "", "Filter by provider, e.g. other")
+
+	return runCmd
+}
+
+func (m *MachineCmd) get() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "get <id|name>",
+		Short: "Show machine detail",
+		Long:  `Show machine detail by ID, or by name (domain or address of the machine).`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err := requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			server, err := findServer(ctx, client, args[0])
+			if err != nil {
+				return err
+			}
+
+			return output.Print(newMachineRecord(*server, client.FrontendURL))
+		},
+	}
+
+	return runCmd
+}
+
+func (m *MachineCmd) delete() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "delete [id|name]",
+		Short: "Delete machine from dPanel",
+		Long: `Delete machine from dPanel by ID, or by name (domain or address of the machine).
+When no machine given, delete the machine registered from this machine.
+
+Use --local to also remove dPanel SSH key from authorized_keys, the sudoers drop-in written by --grant-sudo
+and ~/.devetek/machine.json, only allowed for the machine registered from this host.
+Use --delete-router to remove the router created by 'dnocs machine create --behind-tunnel'.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// registration record of this machine, can be empty
+			localMachine, err := api.ReadMachine()
+			if err != nil {
+				return stepError(ctx, "reading ~/.devetek/machine.json", err)
+			}
+
+			var ref string
+			if len(args) > 0 {
+				ref = args[0]
+			} else if localMachine != nil {
+				ref = strconv.FormatUint(localMachine.GetUint64ID(), 10)
+			} else {
+				return validationError("This machine is not registered, set machine ID or name to delete")
+			}
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err = requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			server, err := findServer(ctx, client, ref)
+			if err != nil {
+				return err
+			}
+
+			// --local clean up this host, dPanel key is shared by other machines and must stay authorized here
+			var isLocal = localMachine != nil && localMachine.GetUint64ID() == server.GetUint64ID()
+			if m.local && !isLocal {
+				return validationError("--local only clean up the machine registered from this host, machine %d was not registered here, delete it without --local", server.ID)
+			}
+
+			var record = machineDeleteRecord{ID: server.GetUint64ID()}
+
+			_, err = client.DeleteServerContext(ctx, record.ID)
+			if err != nil {
+				return stepError(ctx, fmt.Sprintf("deleting machine %d", record.ID), err)
+			}
+
+			record.Deleted = true
+			logger.Success(fmt.Sprintf("Machine %d deleted from dPanel", record.ID))
+
+			// machine already deleted, continue cleanup and report all failed steps at the end
+			var cleanupErrs []error
+
+			if m.deleteRouter {
+				if server.Domain == "" {
+					logger.Normal("Machine has no domain, no router to delete")
+				} else {
+					router, err := findRouterByDomain(ctx, client, server.Domain)
+					if err != nil {
+						cleanupErrs = append(cleanupErrs, err)
+					} else if router == nil {
+						logger.Normal("No router found for domain " + server.Domain)
+					} else {
+						_, err = client.DeleteRouterContext(ctx, uint64(router.ID))
+						if err != nil {
+							cleanupErrs = append(cleanupErrs, stepError(ctx, fmt.Sprintf("deleting router %d", router.ID), err))
+						} else {
+							record.RouterID = uint64(router.ID)
+							logger.Success(fmt.Sprintf("Router %d (%s) deleted", router.ID, router.Domain))
+						}
+					}
+				}
+			}
+
+			if m.local {
+				// remove dPanel public key added by machine create
+				secret, err := client.GetSecretSSHByIDContext(ctx, fmt.Sprint(server.SecretID))
+				if err != nil {
+					cleanupErrs = append(cleanupErrs, stepError(ctx, "getting detail secret ssh", err))
+				} else {
+					record.KeyRemoved, err = removeMachineKey(server.SSHUser, secret.Data.Data.Data()["public"])
+					if err != nil {
+						cleanupErrs = append(cleanupErrs, stepError(ctx, "removing ssh key from authorized_keys file", err))
+					} else if record.KeyRemoved {
+						logger.Success("dPanel SSH key removed from authorized_keys")
+					} else {
+						logger.Normal("dPanel SSH key is not managed by dnocs in authorized_keys, keep it")
+					}
+				}
+
+				record.SudoersRemoved, err = removeSudoersDropIn(server.SSHUser)
+				if err != nil {
+					cleanupErrs = append(cleanupErrs, stepError(ctx, "removing sudoers drop-in of "+server.SSHUser, err))
+				}
+
+				err = api.RemoveMachine()
+				if err != nil {
+					cleanupErrs = append(cleanupErrs, stepError(ctx, "removing ~/.devetek/machine.json", err))
+				} else {
+					record.RecordRemoved = true
+					logger.Success("Registration record ~/.devetek/machine.json removed")
+				}
+			}
+
+			err = output.Print(record)
+			if err != nil {
+				return err
+			}
+
+			if len(cleanupErrs) > 0 {
+				return partialError("Machine %d deleted, but cleanup failed: %w", record.ID, errors.Join(cleanupErrs...))
+			}
+
+			return nil
+		},
+	}
+
+	runCmd.PersistentFlags().BoolVarP(&m.local, "local", "", false, "Also remove dPanel SSH key, sudoers drop-in and registration record from this host, only for the machine registered from it")
+	runCmd.PersistentFlags().BoolVarP(&m.deleteRouter, "delete-router", "", false, "Also delete router created by --behind-tunnel")
+
+	return runCmd
+}
+
+func (m *MachineCmd) status() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show registration status of this machine",
+		Long:  `Show machine registered from this machine, read from ~/.devetek/machine.json, and its current status in dPanel.`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			machine, err := api.ReadMachine()
+			if err != nil {
+				return stepError(ctx, "reading ~/.devetek/machine.json", err)
+			}
+
+			if machine == nil {
+				if output.IsStructured() {
+					return output.Print(machineStatusRecord{})
+				}
+
+				logger.Normal("This machine is not registered, use command 'dnocs machine create' to register it")
+				return nil
+			}
+
+			var record = machineStatusRecord{
+				Registered: true,
+				ID:         machine.GetUint64ID(),
+				Address:    machine.Address,
+				SSHPort:    fmt.Sprint(machine.SSHPort),
+				HTTPPort:   fmt.Sprint(machine.HTTPPort),
+				Domain:     machine.Domain,
+				SSHUser:    machine.SSHUser,
+				Status:     "unknown",
+			}
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// local record is still useful without session
+			if !client.HasSession() {
+				logger.Normal("Not logged in, status in dPanel is unknown")
+				return output.Print(record)
+			}
+
+			server, err := client.GetServerContext(ctx, record.ID)
+			switch {
+			case err == nil:
+				record.Status = fmt.Sprint(server.Data.Status)
+			case api.IsNotFound(err):
+				record.Status = "not found"
+				logger.Normal("Machine no longer exist in dPanel, use command 'dnocs machine forget' to clear the registration record")
+			case ctx.Err() != nil:
+				return stepError(ctx, fmt.Sprintf("getting machine %d", record.ID), err)
+			default:
+				logger.Error(fmt.Sprintf("Failed to get status from dPanel: %s", err))
+			}
+
+			return output.Print(record)
+		},
+	}
+
+	return runCmd
+}
+
+func (m *MachineCmd) forget() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "forget",
+		Short: "Clear registration record of this machine",
+		Long: `Remove ~/.devetek/machine.json and the sudoers drop-in written by 'dnocs machine create --grant-sudo',
+the machine is not deleted from dPanel.
+Use it when the machine was deleted from dPanel web, so this machine can be registered again.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			machine, err := api.ReadMachine()
+			if err != nil {
+				// broken record is removed as well
+				logger.Normal(fmt.Sprintf("Registration record is not readable: %s", err))
+			} else if machine == nil {
+				logger.Normal("This machine is not registered, nothing to forget")
+				return nil
+			}
+
+			err = api.RemoveMachine()
+			if err != nil {
+				return fmt.Errorf("Error removing ~/.devetek/machine.json: %w", err)
+			}
+
+			if machine != nil {
+				logger.Success(fmt.Sprintf("Registration record of machine %d removed", machine.ID))
+			} else {
+				logger.Success("Registration record removed")
+				return nil
+			}
+
+			_, err = removeSudoersDropIn(machine.SSHUser)
+			if err != nil {
+				return partialError("Registration record removed, but removing sudoers drop-in of %s failed: %w", machine.SSHUser, err)
+			}
+
+			return nil
+		},
+	}
+
+	return runCmd
+}
+
+// flags to control polling of machine setup
+func (m *MachineCmd) waitFlags(runCmd *cobra.Command) {
+	runCmd.PersistentFlags().DurationVarP(&m.waitTimeout, "timeout", "", 30*time.Minute, "Maximum time to wait for setup")
+	runCmd.PersistentFlags().DurationVarP(&m.waitInterval, "interval", "", 5*time.Second, "Time between status check")
+}
+
+func (m *MachineCmd) waitCmd() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "wait [id|name]",
+		Short: "Wait until machine setup finished",
+		Long: `Wait until setup of the machine finished, and exit with code 4 when setup failed or 8 when timeout exceeded.
+When no machine given, wait for the machine registered from this machine.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if m.waitTimeout <= 0 || m.waitInterval <= 0 {
+				return validationError("--timeout and --interval must be greater than 0")
+			}
+
+			ref, err := machineRef(args)
+			if err != nil {
+				return stepError(ctx, "reading ~/.devetek/machine.json", err)
+			}
+			if ref == "" {
+				return validationError("This machine is not registered, set machine ID or name to wait")
+			}
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err = requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			server, err := findServer(ctx, client, ref)
+			if err != nil {
+				return err
+			}
+
+			ready, err := waitSetup(ctx, client, server.GetUint64ID(), m.waitTimeout, m.waitInterval, nil)
+			if err != nil {
+				return err
+			}
+
+			logger.Success(fmt.Sprintf("Machine %d is ready", ready.ID))
+
+			return output.Print(newMachineRecord(*ready, client.FrontendURL))
+		},
+	}
+
+	m.waitFlags(runCmd)
+
+	return runCmd
+}
+
+func (m *MachineCmd) logs() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "logs [id|name]",
+		Short: "Show setup and job logs of machine",
+		Long: `Show setup and job logs of machine, use --follow to keep printing new logs until interrupted.
+When no machine given, show logs of the machine registered from this machine.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if m.logInterval <= 0 {
+				return validationError("--interval must be greater than 0")
+			}
+
+			ref, err := machineRef(args)
+			if err != nil {
+				return stepError(ctx, "reading ~/.devetek/machine.json", err)
+			}
+			if ref == "" {
+				return validationError("This machine is not registered, set machine ID or name to show the logs")
+			}
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err = requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			server, err := findServer(ctx, client, ref)
+			if err != nil {
+				return err
+			}
+
+			var logs = &logFollower{client: client, serverID: server.GetUint64ID(), print: printLogStream}
+
+			err = logs.poll(ctx)
+			if err != nil {
+				return stepError(ctx, fmt.Sprintf("getting logs of machine %d", server.ID), err)
+			}
+
+			if !m.follow {
+				return nil
+			}
+
+			err = logs.follow(ctx, m.logInterval)
+			if err != nil {
+				return stepError(ctx, fmt.Sprintf("getting logs of machine %d", server.ID), err)
+			}
+
+			return nil
+		},
+	}
+
+	runCmd.PersistentFlags().BoolVarP(&m.follow, "follow", "f", false, "Keep printing new logs until interrupted")
+	runCmd.PersistentFlags().DurationVarP(&m.logInterval, "interval", "", 2*time.Second, "Time between logs check when following")
 
 	return runCmd
 }
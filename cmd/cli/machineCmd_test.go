@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel-cli/internal/helper"
+	"github.com/devetek/d-panel/pkg/dmachine"
+	"github.com/devetek/d-panel/pkg/dsecret"
+	"go.uber.org/zap"
+)
+
+func TestRegisteredMachine(t *testing.T) {
+	tests := []struct {
+		name string
+		// machine in ~/.devetek/machine.json, zero ID means no record
+		saved  dmachine.ResponseForPrivate
+		wantID uint
+	}{
+		{name: "not registered"},
+		{name: "registered", saved: dmachine.ResponseForPrivate{ID: 42, Address: "10.0.0.42"}, wantID: 42},
+		{name: "deleted from dPanel web", saved: dmachine.ResponseForPrivate{ID: 57, Address: "10.0.0.57"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeDPanel(t, dmachine.ResponseForPrivate{ID: 42, Address: "10.0.0.42"})
+
+			if tt.saved.ID != 0 {
+				if err := api.SaveMachine(tt.saved); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			server, err := registeredMachine(context.Background(), api.NewClient())
+			if err != nil {
+				t.Fatalf("registeredMachine() error = %v", err)
+			}
+
+			var gotID uint
+			if server != nil {
+				gotID = server.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("registeredMachine() = machine %d, want %d", gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestCreateRefuseRegisteredMachine(t *testing.T) {
+	// create check root or sudo before the registration record
+	if !helper.IsSudo() {
+		t.Skip("machine create require root or sudo")
+	}
+
+	fake := newFakeDPanel(t, dmachine.ResponseForPrivate{ID: 42, Address: "10.0.0.42"})
+
+	if err := api.SaveMachine(dmachine.ResponseForPrivate{ID: 42, Address: "10.0.0.42"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewMachineCmd(zap.NewNop()).Connect()
+	cmd.SetArgs([]string{"create", "--ssh-ip", "10.0.0.42"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	err := cmd.ExecuteContext(context.Background())
+	if exitCode(err) != exitValidation {
+		t.Fatalf("exit code = %d, want %d, error = %v", exitCode(err), exitValidation, err)
+	}
+	if !strings.Contains(err.Error(), "already registered as machine 42") {
+		t.Errorf("error = %q, want already registered as machine 42", err)
+	}
+
+	if !fake.received("GET /api/v1/server/detail/42") {
+		t.Error("registered machine was not checked in dPanel")
+	}
+
+	for _, request := range []string{"POST /api/v1/server/create", "GET /api/v1/secret/ssh-key/find", "POST /api/v1/secret/ssh-key/create"} {
+		if fake.received(request) {
+			t.Errorf("%s sent for machine already registered", request)
+		}
+	}
+}
+
+func TestDeleteLocalOnlyRegisteredMachine(t *testing.T) {
+	tests := []struct {
+		name string
+		// machine in ~/.devetek/machine.json, zero ID means no record
+		saved uint
+	}{
+		{name: "other machine registered here", saved: 42},
+		{name: "no registration record"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeDPanel(t,
+				dmachine.ResponseForPrivate{ID: 42, Address: "10.0.0.42"},
+				dmachine.ResponseForPrivate{ID: 57, Address: "10.0.0.57", SSHUser: "deploy"},
+			)
+
+			if tt.saved != 0 {
+				if err := api.SaveMachine(dmachine.ResponseForPrivate{ID: tt.saved}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cmd := NewMachineCmd(zap.NewNop()).Connect()
+			cmd.SetArgs([]string{"delete", "57", "--local"})
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+
+			err := cmd.ExecuteContext(context.Background())
+			if exitCode(err) != exitValidation {
+				t.Fatalf("exit code = %d, want %d, error = %v", exitCode(err), exitValidation, err)
+			}
+
+			for _, request := range []string{"DELETE /api/v1/server/delete/57", "GET /api/v1/secret/ssh-key/detail/0"} {
+				if fake.received(request) {
+					t.Errorf("%s sent when --local rejected", request)
+				}
+			}
+		})
+	}
+}
+
+// sudoers.d in a temp folder with dnocs-deploy, written by dnocs when managed
+func writeTestSudoersDropIn(t *testing.T, managed bool) string {
+	t.Helper()
+
+	var dir = t.TempDir()
+	var defaultDir = helper.SudoersDir
+	helper.SudoersDir = dir
+	t.Cleanup(func() {
+		helper.SudoersDir = defaultDir
+	})
+
+	var content = "deploy ALL=(ALL:ALL) NOPASSWD: ALL\n"
+	if managed {
+		content = "# " + helper.AuthorizedKeyTag + ": passwordless root access of deploy\n" + content
+	}
+
+	var path = helper.SudoersDropInPath("deploy")
+	if err := os.WriteFile(path, []byte(content), 0440); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// --local remove dPanel key and the sudoers drop-in written by create --grant-sudo, drop-in written by hand is kept
+func TestDeleteLocalRemoveSudoersDropIn(t *testing.T) {
+	for _, managed := range []bool{true, false} {
+		t.Run(fmt.Sprintf("managed %v", managed), func(t *testing.T) {
+			account := useTestUser(t, "deploy")
+			var server = dmachine.ResponseForPrivate{ID: 57, Address: "10.0.0.57", SecretID: 1, SSHUser: "deploy"}
+			fake := newFakeDPanel(t, server)
+			path := writeTestSudoersDropIn(t, managed)
+
+			_, _, publicKey := writeTestKeyPair(t, "")
+			fake.secrets = []dsecret.Response{newTestSecret(1, "dpanel-key", publicKey)}
+			if _, err := helper.AuthorizeKey(account, publicKey, helper.AuthorizeOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := api.SaveMachine(server); err != nil {
+				t.Fatal(err)
+			}
+
+			stdout, err := executeCLI(t, "machine", "delete", "--local", "-o", "json")
+			if err != nil {
+				t.Fatalf("machine delete --local error = %v", err)
+			}
+
+			var record machineDeleteRecord
+			if err := json.Unmarshal([]byte(stdout), &record); err != nil {
+				t.Fatalf("machine delete output %q: %v", stdout, err)
+			}
+			var want = machineDeleteRecord{ID: 57, Deleted: true, KeyRemoved: true, RecordRemoved: true, SudoersRemoved: managed}
+			if record != want {
+				t.Errorf("machine delete record = %+v, want %+v", record, want)
+			}
+
+			if _, err := os.Stat(path); (err == nil) == managed {
+				t.Errorf("drop-in exist = %v, want %v", err == nil, !managed)
+			}
+		})
+	}
+}
+
+func TestForgetRemoveSudoersDropIn(t *testing.T) {
+	for _, managed := range []bool{true, false} {
+		t.Run(fmt.Sprintf("managed %v", managed), func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			path := writeTestSudoersDropIn(t, managed)
+
+			if err := api.SaveMachine(dmachine.ResponseForPrivate{ID: 57, SSHUser: "deploy"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := executeCLI(t, "machine", "forget"); err != nil {
+				t.Fatalf("machine forget error = %v", err)
+			}
+
+			if machine, err := api.ReadMachine(); err != nil || machine != nil {
+				t.Errorf("registration record after forget = %+v, %v", machine, err)
+			}
+			if _, err := os.Stat(path); (err == nil) == managed {
+				t.Errorf("drop-in exist = %v, want %v", err == nil, !managed)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel-cli/internal/helper"
+	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
+	"github.com/devetek/d-panel/pkg/dmachine"
+	"github.com/spf13/cobra"
+)
+
+// replaced in tests, which can not change authorized_keys of the real user or login with sshd
+var (
+	lookupCurrentUser = user.Current
+	checkSSHLogin     = helper.CheckSSHLogin
+)
+
+type rotateKeyRecord struct {
+	ID             uint64 `json:"id" yaml:"id"`
+	OldSecretID    uint64 `json:"old_secret_id" yaml:"old_secret_id"`
+	NewSecretID    uint64 `json:"new_secret_id" yaml:"new_secret_id"`
+	NewFingerprint string `json:"new_fingerprint" yaml:"new_fingerprint"`
+	OldKeyRemoved  bool   `json:"old_key_removed" yaml:"old_key_removed"`
+}
+
+// steps to undo when rotation failed, executed in reverse order
+type rollbackSteps []func(ctx context.Context) error
+
+func (r *rollbackSteps) add(step func(ctx context.Context) error) {
+	*r = append(*r, step)
+}
+
+// undo all changes, and return cause of the failure
+func (r rollbackSteps) run(ctx context.Context, cause error) error {
+	// rollback must finish even when the command was interrupted
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+
+	var errs []error
+	for i := len(r) - 1; i >= 0; i-- {
+		if err := r[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return partialError("%w, and rollback failed: %w", cause, errors.Join(errs...))
+	}
+
+	if len(r) > 0 {
+		logger.Normal("Changes rolled back")
+	}
+
+	return cause
+}
+
+// payload to update server, keep every field except the SSH key
+func serverPayload(server dmachine.ResponseForPrivate, secretID uint64) dmachine.Payload {
+	return dmachine.Payload{
+		Provider: server.Provider,
+		SecretID: fmt.Sprint(secretID),
+		Address:  server.Address,
+		SSHPort:  fmt.Sprint(server.SSHPort),
+		HTTPPort: fmt.Sprint(server.HTTPPort),
+		Domain:   server.Domain,
+		SSHUser:  server.SSHUser,
+	}
+}
+
+func (m *MachineCmd) rotateKey() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "rotate-key",
+		Short: "Rotate SSH key used by dPanel to access this machine",
+		Long: `Replace SSH key used by dPanel to access this machine with a new key:
+
+  1. create new SSH key in dPanel
+  2. append the new public key to authorized_keys
+  3. confirm sshd accept login with the new key, then apply the restriction of the old key to it
+  4. update the machine in dPanel to use the new key
+  5. remove the old public key from authorized_keys
+
+Every change is rolled back when one of the steps failed. The old key is kept in dPanel,
+delete it with 'dnocs secret ssh delete' when it is not used anymore.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			err := validateKeySize(m.keySize)
+			if err != nil {
+				return err
+			}
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err = requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			server, err := registeredMachine(ctx, client)
+			if err != nil {
+				return err
+			}
+			if server == nil {
+				return validationError("This machine is not registered, use command 'dnocs machine create' first")
+			}
+
+			// key is authorized in home of the SSH user of the machine
+			sshUser, err := machineSSHUser(server.SSHUser)
+			if err != nil {
+				return err
+			}
+
+			oldSecret, err := findSecretSSHByID(ctx, client, fmt.Sprint(server.SecretID))
+			if err != nil {
+				return err
+			}
+			var oldPublicKey = strings.TrimSpace(oldSecret.Data.Data()["public"])
+
+			var record = rotateKeyRecord{ID: server.GetUint64ID(), OldSecretID: uint64(oldSecret.ID)}
+			var rollback rollbackSteps
+
+			// 1. create new SSH key
+			newSecret, err := client.CreateSecretSSHContext(ctx, api.CreateSecretSSHOptions{Name: m.keyName, KeySize: m.keySize})
+			if err != nil {
+				return stepError(ctx, "creating secret ssh", err)
+			}
+			rollback.add(func(ctx context.Context) error {
+				_, err := client.DeleteSecretSSHContext(ctx, fmt.Sprint(newSecret.Data.ID))
+				if err != nil {
+					return fmt.Errorf("deleting secret ssh %d: %w", newSecret.Data.ID, err)
+				}
+				return nil
+			})
+			record.NewSecretID = uint64(newSecret.Data.ID)
+			logger.Success(fmt.Sprintf("Secret ssh %d (%s) created", newSecret.Data.ID, newSecret.Data.Name))
+
+			// detail contains the private key, used to confirm login
+			detail, err := findSecretSSHByID(ctx, client, fmt.Sprint(newSecret.Data.ID))
+			if err != nil {
+				return rollback.run(ctx, err)
+			}
+			var newPublicKey = strings.TrimSpace(detail.Data.Data()["public"])
+			var newPrivateKey = detail.Data.Data()["private"]
+			record.NewFingerprint = helper.SSHFingerprint(newPublicKey)
+
+			if newPublicKey == "" {
+				return rollback.run(ctx, fmt.Errorf("Secret ssh %d has no public key", newSecret.Data.ID))
+			}
+
+			// 2. append the new public key, with the same restriction as the old key
+			authorizedKeys, err := helper.LoadAuthorizedKeys(sshUser)
+			if err != nil {
+				return rollback.run(ctx, stepError(ctx, "reading authorized_keys file", err))
+			}
+
+			var keyOptions helper.AuthorizeOptions
+			if oldKey := authorizedKeys.Find(oldPublicKey); oldKey != nil {
+				keyOptions.Options = oldKey.Options
+			}
+
+			// login check connect from localhost, which is rejected by from= restriction,
+			// the restriction is applied after the check
+			var verifyOptions = keyOptions
+			if !m.skipVerify {
+				verifyOptions.Options = withoutFromOption(keyOptions.Options)
+			}
+
+			_, err = helper.AuthorizeKey(sshUser, newPublicKey, verifyOptions)
+			if err != nil {
+				return rollback.run(ctx, stepError(ctx, "appending ssh key to authorized_keys file", err))
+			}
+			rollback.add(func(ctx context.Context) error {
+				_, err := helper.UnauthorizeKey(sshUser, newPublicKey)
+				if err != nil {
+					return fmt.Errorf("removing new ssh key from authorized_keys file: %w", err)
+				}
+				return nil
+			})
+			logger.Success("New public key appended to authorized_keys")
+
+			// 3. confirm sshd accept the new key
+			if m.skipVerify {
+				logger.Normal("Skip login check with the new key")
+			} else {
+				if newPrivateKey == "" {
+					return rollback.run(ctx, validationError("dPanel did not return private key of secret ssh %d, use --skip-verify to rotate without login check", newSecret.Data.ID))
+				}
+
+				var address = net.JoinHostPort("localhost", localSSHPort())
+				err = checkSSHLogin(ctx, address, server.SSHUser, []byte(newPrivateKey))
+				if err != nil {
+					return rollback.run(ctx, stepError(ctx, "login to "+address+" with the new key", err))
+				}
+				logger.Success(fmt.Sprintf("sshd at %s accept login with the new key", address))
+
+				if len(verifyOptions.Options) != len(keyOptions.Options) {
+					_, err = helper.AuthorizeKey(sshUser, newPublicKey, keyOptions)
+					if err != nil {
+						return rollback.run(ctx, stepError(ctx, "restricting new ssh key in authorized_keys file", err))
+					}
+					logger.Success("Source address restriction of the old key applied to the new key")
+				}
+			}
+
+			// 4. switch dPanel to the new key
+			_, err = client.UpdateServerContext(ctx, record.ID, serverPayload(*server, record.NewSecretID))
+			if err != nil {
+				return rollback.run(ctx, stepError(ctx, fmt.Sprintf("updating machine %d", record.ID), err))
+			}
+			logger.Success(fmt.Sprintf("Machine %d updated to use secret ssh %d", record.ID, record.NewSecretID))
+
+			// keep registration record in sync
+			server.SecretID = newSecret.Data.ID
+			_ = api.SaveMachine(*server)
+
+			// 5. remove the old key, dPanel already use the new key so nothing to roll back
+			if oldPublicKey != "" && oldPublicKey != newPublicKey {
+				record.OldKeyRemoved, err = helper.UnauthorizeKey(sshUser, oldPublicKey)
+				if err != nil {
+					if printErr := output.Print(record); printErr != nil {
+						return printErr
+					}
+					return partialError("Machine %d use the new key, but removing the old key from authorized_keys failed: %w", record.ID, err)
+				}
+			}
+			if record.OldKeyRemoved {
+				logger.Success("Old public key removed from authorized_keys")
+			} else if oldPublicKey != "" && oldPublicKey != newPublicKey {
+				logger.Normal("Old public key is not managed by dnocs in authorized_keys, keep it")
+			}
+
+			return output.Print(record)
+		},
+	}
+
+	runCmd.PersistentFlags().StringVarP(&m.keyName, "name", "n", "", "Name of the new SSH key (default cli-ssh-key-<date time>)")
+	runCmd.PersistentFlags().IntVarP(&m.keySize, "key-size", "", api.DefaultSSHKeySize, "Size of the new RSA key, 2048, 3072 or 4096")
+	runCmd.PersistentFlags().BoolVarP(&m.skipVerify, "skip-verify", "", false, "Do not confirm login with the new key before switching dPanel to it")
+
+	return runCmd
+}
+
+// options of authorized_keys without from= source address restriction
+func withoutFromOption(options []string) []string {
+	var result []string
+	for _, option := range options {
+		if strings.HasPrefix(strings.ToLower(option), "from=") {
+			continue
+		}
+		result = append(result, option)
+	}
+
+	return result
+}
+
+// port of sshd in this machine, machine behind tunnel is registered with tunnel port
+func localSSHPort() string {
+	config, err := helper.ReadSSHDConfig(helper.SSHDConfigPath)
+	if err != nil {
+		return "22"
+	}
+
+	return config.Port()
+}
+
+// account of the SSH user of the machine, its authorized_keys can only be changed by itself or root
+func machineSSHUser(username string) (*user.User, error) {
+	currentUser, err := lookupCurrentUser()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting current user: %w", err)
+	}
+
+	if username == "" || username == currentUser.Username {
+		return currentUser, nil
+	}
+
+	sshUser, err := user.Lookup(username)
+	if err != nil {
+		return nil, validationError("SSH user %s of this machine not found: %s", username, err)
+	}
+
+	if currentUser.Uid != "0" {
+		return nil, permissionError("Run this command as root or %s to change authorized_keys of %s", username, username)
+	}
+
+	return sshUser, nil
+}
+
+// user used by dPanel to login to this machine, current user when username is empty.
+// Other user is created when not exist, and allowed to run any command as root with sudoers drop-in when grantSudo is set.
+func prepareSSHUser(ctx context.Context, username string, grantSudo bool) (*user.User, error) {
+	currentUser, err := lookupCurrentUser()
+	if err != nil {
+		return nil, stepError(ctx, "getting current user", err)
+	}
+
+	if username == "" || username == currentUser.Username {
+		return currentUser, nil
+	}
+
+	account, created, err := helper.EnsureUser(username)
+	if err != nil {
+		return nil, stepError(ctx, "creating user "+username, err)
+	}
+	if created {
+		logger.Success(fmt.Sprintf("User %s created", username))
+	} else {
+		logger.Normal(fmt.Sprintf("User %s already exist, using it", username))
+	}
+
+	if !grantSudo {
+		logger.Normal(fmt.Sprintf("dPanel setup require passwordless sudo for %s, grant it yourself or use --grant-sudo", username))
+		return account, nil
+	}
+
+	path, err := helper.WriteSudoersDropIn(username)
+	if err != nil {
+		return nil, stepError(ctx, "writing sudoers drop-in for "+username, err)
+	}
+	logger.Success(fmt.Sprintf("User %s can run any command as root without password, granted by %s", username, path))
+
+	return account, nil
+}
+
+// remove sudoers drop-in written by machine create --grant-sudo
+func removeSudoersDropIn(username string) (bool, error) {
+	if username == "" {
+		return false, nil
+	}
+
+	removed, err := helper.RemoveSudoersDropIn(username)
+	if err != nil {
+		return false, err
+	}
+	if removed {
+		logger.Success(fmt.Sprintf("Sudoers drop-in %s removed", helper.SudoersDropInPath(username)))
+	}
+
+	return removed, nil
+}
+
+// remove dPanel public key from authorized_keys of the SSH user of the machine
+func removeMachineKey(username, publicKey string) (bool, error) {
+	if strings.TrimSpace(publicKey) == "" {
+		return false, nil
+	}
+
+	sshUser, err := machineSSHUser(username)
+	if err != nil {
+		return false, err
+	}
+
+	return helper.UnauthorizeKey(sshUser, publicKey)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/user"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel-cli/internal/helper"
+	"github.com/devetek/d-panel/pkg/dmachine"
+	"github.com/devetek/d-panel/pkg/dsecret"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestWithoutFromOption(t *testing.T) {
+	tests := []struct {
+		options []string
+		want    []string
+	}{
+		{options: nil, want: nil},
+		{options: []string{`from="203.0.113.10"`}, want: nil},
+		{options: []string{`FROM="203.0.113.10"`, "no-pty"}, want: []string{"no-pty"}},
+		{options: []string{"no-port-forwarding", `command="echo from=x"`}, want: []string{"no-port-forwarding", `command="echo from=x"`}},
+	}
+
+	for _, tt := range tests {
+		if got := withoutFromOption(tt.options); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("withoutFromOption(%q) = %q, want %q", tt.options, got, tt.want)
+		}
+	}
+}
+
+// run as the current user renamed to username, with a temp home folder, so authorized_keys of the real user is not changed
+func useTestUser(t *testing.T, username string) *user.User {
+	t.Helper()
+
+	current, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var account = *current
+	account.HomeDir = t.TempDir()
+	if username != "" {
+		account.Username = username
+	}
+
+	if path := helper.AuthorizedKeysPath(&account); !strings.HasPrefix(path, account.HomeDir) {
+		t.Skipf("AuthorizedKeysFile of sshd_config is outside home folder: %s", path)
+	}
+
+	lookupCurrentUser = func() (*user.User, error) { return &account, nil }
+	t.Cleanup(func() {
+		lookupCurrentUser = user.Current
+	})
+
+	return &account
+}
+
+// fake dPanel with this machine registered, authorized_keys of the SSH user in a temp home folder
+// with the old key restricted to a source address, and login check replaced by loginErr
+func newRotateKeyTest(t *testing.T, loginErr error) (*fakeDPanel, *user.User, string) {
+	t.Helper()
+
+	account := useTestUser(t, "")
+	checkSSHLogin = func(ctx context.Context, address string, username string, privateKey []byte) error {
+		if username != account.Username {
+			t.Errorf("login check as %s, want %s", username, account.Username)
+		}
+		if _, err := ssh.ParsePrivateKey(privateKey); err != nil {
+			t.Errorf("login check with invalid private key: %v", err)
+		}
+		return loginErr
+	}
+	t.Cleanup(func() {
+		checkSSHLogin = helper.CheckSSHLogin
+	})
+
+	var server = dmachine.ResponseForPrivate{ID: 1, Address: "10.0.0.1", SSHPort: "22", SecretID: 1, SSHUser: account.Username}
+	fake := newFakeDPanel(t, server)
+
+	_, _, oldKey := writeTestKeyPair(t, "")
+	fake.secrets = []dsecret.Response{newTestSecret(1, "old-key", oldKey)}
+
+	if err := api.SaveMachine(server); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := helper.AuthorizeKey(account, oldKey, helper.AuthorizeOptions{From: []string{"203.0.113.10"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	return fake, account, oldKey
+}
+
+// failed step roll back the new key in authorized_keys and the new secret in dPanel
+func TestMachineRotateKeyRollback(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []string
+		loginErr error
+		// authorized_keys can not be read
+		brokenAuthorizedKeys bool
+		wantCode             int
+		// nothing to roll back when the new secret is not created
+		wantDelete bool
+		// new secret is kept when its deletion failed
+		wantSecrets int
+	}{
+		{name: "create secret failed", failures: []string{"POST /api/v1/secret/ssh-key/create"}, wantCode: exitAPI, wantDelete: false, wantSecrets: 1},
+		{name: "get new secret failed", failures: []string{"GET /api/v1/secret/ssh-key/detail/2"}, wantCode: exitAPI, wantDelete: true, wantSecrets: 1},
+		{name: "read authorized_keys failed", brokenAuthorizedKeys: true, wantCode: exitGeneral, wantDelete: true, wantSecrets: 1},
+		{name: "login with new key failed", loginErr: errors.New("ssh: unable to authenticate"), wantCode: exitGeneral, wantDelete: true, wantSecrets: 1},
+		{name: "update server failed", failures: []string{"PUT /api/v1/server/update/1"}, wantCode: exitAPI, wantDelete: true, wantSecrets: 1},
+		{
+			name:        "update server and rollback failed",
+			failures:    []string{"PUT /api/v1/server/update/1", "DELETE /api/v1/secret/ssh-key/delete/2"},
+			wantCode:    exitPartial,
+			wantDelete:  true,
+			wantSecrets: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, account, oldKey := newRotateKeyTest(t, tt.loginErr)
+			for _, request := range tt.failures {
+				fake.fail(request, http.StatusInternalServerError)
+			}
+
+			var path = helper.AuthorizedKeysPath(account)
+			before, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.brokenAuthorizedKeys {
+				// reading a folder fail, also for root
+				if err := os.Rename(path, path+".bak"); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Mkdir(path, 0700); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err = executeCLI(t, "machine", "rotate-key", "-o", "json")
+			if err == nil {
+				t.Fatal("rotate-key error = nil, want failure")
+			}
+			if got := exitCode(err); got != tt.wantCode {
+				t.Errorf("rotate-key exit code = %d, want %d: %v", got, tt.wantCode, err)
+			}
+
+			if tt.brokenAuthorizedKeys {
+				_ = os.Remove(path)
+				_ = os.Rename(path+".bak", path)
+			}
+
+			after, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(after) != string(before) {
+				t.Errorf("authorized_keys after rollback:\n%s\nwant:\n%s", after, before)
+			}
+
+			if len(fake.secrets) != tt.wantSecrets {
+				t.Errorf("dPanel has %d secret after rollback, want %d", len(fake.secrets), tt.wantSecrets)
+			}
+			if got := fake.received("DELETE /api/v1/secret/ssh-key/delete/2"); got != tt.wantDelete {
+				t.Errorf("delete new secret 2 requested = %v, want %v", got, tt.wantDelete)
+			}
+			if fake.servers[0].SecretID != 1 {
+				t.Errorf("machine use secret %d after rollback, want 1", fake.servers[0].SecretID)
+			}
+
+			keys, err := helper.LoadAuthorizedKeys(account)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keys.Find(oldKey) == nil {
+				t.Error("old key removed from authorized_keys")
+			}
+		})
+	}
+}
+
+func TestMachineRotateKey(t *testing.T) {
+	fake, account, oldKey := newRotateKeyTest(t, nil)
+
+	stdout, err := executeCLI(t, "machine", "rotate-key", "--name=new-key", "-o", "json")
+	if err != nil {
+		t.Fatalf("rotate-key error = %v", err)
+	}
+
+	var record rotateKeyRecord
+	if err := json.Unmarshal([]byte(stdout), &record); err != nil {
+		t.Fatalf("rotate-key output %q: %v", stdout, err)
+	}
+	if record.ID != 1 || record.OldSecretID != 1 || record.NewSecretID != 2 || !record.OldKeyRemoved {
+		t.Errorf("rotate-key record = %+v", record)
+	}
+
+	var payload dmachine.Payload
+	if err := json.Unmarshal(fake.bodies["PUT /api/v1/server/update/1"], &payload); err != nil {
+		t.Fatal(err)
+	}
+	if want := (dmachine.Payload{SecretID: "2", Address: "10.0.0.1", SSHPort: "22", SSHUser: account.Username}); payload != want {
+		t.Errorf("update server payload = %+v, want %+v", payload, want)
+	}
+	if fake.servers[0].SecretID != 2 {
+		t.Errorf("machine use secret %d, want 2", fake.servers[0].SecretID)
+	}
+
+	machine, err := api.ReadMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if machine.SecretID != 2 {
+		t.Errorf("machine.json use secret %d, want 2", machine.SecretID)
+	}
+
+	keys, err := helper.LoadAuthorizedKeys(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.Find(oldKey) != nil {
+		t.Error("old key is kept in authorized_keys")
+	}
+	newKey := keys.Find(fake.secrets[1].Data.Data()["public"])
+	if newKey == nil {
+		t.Fatal("new key not found in authorized_keys")
+	}
+	if want := []string{`from="203.0.113.10"`}; !reflect.DeepEqual(newKey.Options, want) {
+		t.Errorf("new key options = %q, want %q", newKey.Options, want)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel-cli/internal/tunnel"
+	"github.com/devetek/d-panel/pkg/dmachine"
+	"github.com/devetek/d-panel/pkg/drouter"
+	"github.com/devetek/tuman/pkg/marijan"
+)
+
+var (
+	domainPattern   = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]$`)
+	invalidDNSLabel = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// ports of tunnel created by 'dnocs tunnel create'
+type tunnelPorts struct {
+	// SSH of this machine, exposed in tunnel host
+	sshHost string
+	sshPort string
+	// port in tunnel host forwarded to dPanel agent of this machine
+	httpListenerPort string
+	// port of dPanel agent in this machine
+	httpServicePort string
+}
+
+func readTunnelPorts(configs []marijan.Config) (tunnelPorts, error) {
+	var ports tunnelPorts
+	for _, config := range configs {
+		if strings.Contains(config.ID, "ssh-") {
+			ports.sshHost = config.TunnelHost
+			ports.sshPort = config.ListenerPort
+		}
+
+		if strings.Contains(config.ID, "http-") {
+			ports.httpListenerPort = config.ListenerPort
+			ports.httpServicePort = config.ServicePort
+		}
+	}
+
+	if ports.sshPort == "" || ports.httpListenerPort == "" {
+		return ports, validationError("Tunnel config must have SSH and HTTP tunnel, use command 'dnocs tunnel create' to recreate it")
+	}
+
+	return ports, nil
+}
+
+// router in gateway machine, proxy domain to tunnel listener of dPanel agent
+func tunnelRouterPayload(ports tunnelPorts, gatewayID uint, domain string) drouter.PayloadRouter {
+	return drouter.PayloadRouter{
+		AdvanceMode: false,
+		Type:        "proxy_pass",
+		Name:        fmt.Sprintf("http-%s-to-%s", ports.httpListenerPort, ports.httpServicePort),
+		Domain:      domain,
+		MachineID:   gatewayID,
+		Upstream:    fmt.Sprintf("localhost:%s", ports.httpListenerPort),
+	}
+}
+
+// domain of dPanel agent behind tunnel, e.g. my-machine-31234.tunnel.beta.devetek.app.
+// Listener port is unique in the tunnel host, so the domain is unique for every machine.
+func tunnelRouterDomain(hostname, listenerPort, tunnelHost string) string {
+	var suffix = "-" + listenerPort
+
+	label := strings.Trim(invalidDNSLabel.ReplaceAllString(strings.ToLower(hostname), "-"), "-")
+	if len(label)+len(suffix) > 63 {
+		label = strings.TrimRight(label[:63-len(suffix)], "-")
+	}
+	if label == "" {
+		label = "machine"
+	}
+
+	return label + suffix + "." + strings.ToLower(tunnelHost)
+}
+
+func isValidDomain(domain string) bool {
+	return len(domain) <= 253 && domainPattern.MatchString(domain)
+}
+
+func currentHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+
+	// short name, without domain of local network
+	hostname, _, _ = strings.Cut(hostname, ".")
+
+	return hostname
+}
+
+// find machine serving the tunnel host, from --gateway-machine, active context, or by tunnel host address.
+// Lookup by tunnel host only search machines of the account, the shared dPanel tunnel gateway is not owned
+// by ordinary account, so it is only found when the account run its own tunnel host.
+func resolveGateway(ctx context.Context, client *api.Client, ref string) (*dmachine.ResponseForPrivate, error) {
+	if ref == "" {
+		profile, err := api.CurrentProfile()
+		if err != nil {
+			return nil, validationError("%s", err)
+		}
+
+		ref = profile.GatewayMachine
+	}
+
+	if ref != "" {
+		return findServer(ctx, client, ref)
+	}
+
+	gateway, err := findServer(ctx, client, tunnel.TunnelHost)
+	if err != nil {
+		if exitCode(err) == exitValidation {
+			return nil, validationError("Gateway machine of tunnel host %s not found in your machines. "+
+				"The shared dPanel tunnel gateway is not owned by your account, ask dPanel admin for its machine ID, "+
+				"then set it with --gateway-machine or 'dnocs context add --gateway-machine'", tunnel.TunnelHost)
+		}
+
+		return nil, err
+	}
+
+	return gateway, nil
+}
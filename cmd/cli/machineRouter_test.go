@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel-cli/internal/tunnel"
+	"github.com/devetek/d-panel/pkg/dmachine"
+)
+
+func TestTunnelRouterPayloadUseResolvedGateway(t *testing.T) {
+	var ports = tunnelPorts{
+		sshHost:          tunnel.TunnelHost,
+		sshPort:          "2221",
+		httpListenerPort: "31234",
+		httpServicePort:  "9000",
+	}
+
+	tests := []struct {
+		name string
+		// --gateway-machine flag
+		ref string
+		// gateway machine in the active context
+		contextGateway string
+		wantID         uint
+		wantErr        string
+	}{
+		{name: "flag by ID", ref: "42", wantID: 42},
+		{name: "flag by domain", ref: "https://gateway.example.com", wantID: 42},
+		{name: "context", contextGateway: "43", wantID: 43},
+		{name: "flag has priority over context", ref: "43", contextGateway: "42", wantID: 43},
+		{name: "owned tunnel host", wantID: 44},
+		{name: "flag not found", ref: "404", wantErr: "Machine 404 not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var servers = []dmachine.ResponseForPrivate{
+				{ID: 11, Address: "10.0.0.11", Domain: "other.example.com"},
+				{ID: 42, Address: "10.0.0.42", Domain: "gateway.example.com"},
+				{ID: 43, Address: "10.0.0.43", Domain: "gateway-2.example.com"},
+			}
+			if tt.wantID == 44 {
+				servers = append(servers, dmachine.ResponseForPrivate{ID: 44, Address: tunnel.TunnelHost})
+			}
+
+			newFakeDPanel(t, servers...)
+
+			if tt.contextGateway != "" {
+				writeTestConfig(t, `{"current_context":"dev","contexts":[{"name":"dev","api_base_url":"http://localhost","gateway_machine":"`+tt.contextGateway+`"}]}`)
+			}
+
+			gateway, err := resolveGateway(context.Background(), api.NewClient(), tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveGateway() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveGateway() error = %v", err)
+			}
+
+			var domain = tunnelRouterDomain("My_Machine", ports.httpListenerPort, tunnel.TunnelHost)
+			payload := tunnelRouterPayload(ports, gateway.ID, domain)
+
+			if payload.MachineID != tt.wantID {
+				t.Errorf("MachineID = %d, want gateway %d", payload.MachineID, tt.wantID)
+			}
+			if payload.Domain != "my-machine-31234."+tunnel.TunnelHost {
+				t.Errorf("Domain = %q, want %q", payload.Domain, "my-machine-31234."+tunnel.TunnelHost)
+			}
+			if payload.Upstream != "localhost:31234" {
+				t.Errorf("Upstream = %q, want localhost:31234", payload.Upstream)
+			}
+		})
+	}
+}
+
+func TestResolveGatewayNotOwned(t *testing.T) {
+	newFakeDPanel(t, dmachine.ResponseForPrivate{ID: 11, Address: "10.0.0.11"})
+
+	_, err := resolveGateway(context.Background(), api.NewClient(), "")
+	if exitCode(err) != exitValidation {
+		t.Fatalf("exit code = %d, want %d, error = %v", exitCode(err), exitValidation, err)
+	}
+
+	if !strings.Contains(err.Error(), "not owned by your account") || !strings.Contains(err.Error(), "--gateway-machine") {
+		t.Errorf("error should explain the shared gateway and suggest --gateway-machine, got %q", err)
+	}
+}
+
+func TestTunnelRouterDomain(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     string
+	}{
+		{hostname: "web-01", want: "web-01-31234.tunnel.example.com"},
+		{hostname: "Web_Server.local", want: "web-server-local-31234.tunnel.example.com"},
+		{hostname: "", want: "machine-31234.tunnel.example.com"},
+		{hostname: strings.Repeat("a", 80), want: strings.Repeat("a", 57) + "-31234.tunnel.example.com"},
+	}
+
+	for _, tt := range tests {
+		got := tunnelRouterDomain(tt.hostname, "31234", "Tunnel.Example.com")
+		if got != tt.want {
+			t.Errorf("tunnelRouterDomain(%q) = %q, want %q", tt.hostname, got, tt.want)
+		}
+		if !isValidDomain(got) {
+			t.Errorf("tunnelRouterDomain(%q) = %q is not a valid domain", tt.hostname, got)
+		}
+	}
+}
+
+// write ~/.devetek/config.json in the test home folder
+func writeTestConfig(t *testing.T, content string) {
+	t.Helper()
+
+	var dir = filepath.Join(os.Getenv("HOME"), ".devetek")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
+	"github.com/devetek/d-panel/pkg/dmachine"
+	"golang.org/x/term"
+)
+
+// setup state of machine, read from its status in dPanel
+type setupState int
+
+const (
+	setupRunning setupState = iota
+	setupReady
+	setupFailed
+)
+
+// machine status in dPanel, matched exactly without case, keep in sync with dmachine
+const (
+	machineStatusPending    = "pending"
+	machineStatusSetup      = "setup"
+	machineStatusInstalling = "installing"
+	machineStatusReady      = "ready"
+	machineStatusActive     = "active"
+	machineStatusFailed     = "failed"
+	machineStatusError      = "error"
+)
+
+var machineSetupStates = map[string]setupState{
+	machineStatusPending:    setupRunning,
+	machineStatusSetup:      setupRunning,
+	machineStatusInstalling: setupRunning,
+	machineStatusReady:      setupReady,
+	machineStatusActive:     setupReady,
+	machineStatusFailed:     setupFailed,
+	machineStatusError:      setupFailed,
+}
+
+// setup state of machine status, known is false for status not listed above,
+// it is treated as setup still running until timeout
+func machineSetupState(status string) (state setupState, known bool) {
+	state, known = machineSetupStates[strings.ToLower(strings.TrimSpace(status))]
+	return state, known
+}
+
+// progress of machine setup, print every status change,
+// and a spinner with elapsed time when stderr is a terminal
+type setupProgress struct {
+	serverID uint64
+	start    time.Time
+	status   string
+	// last unknown status reported
+	unknownStatus string
+	frame         int
+	spinner       bool
+}
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+func newSetupProgress(serverID uint64) *setupProgress {
+	return &setupProgress{
+		serverID: serverID,
+		start:    time.Now(),
+		spinner:  !output.IsStructured() && term.IsTerminal(int(os.Stderr.Fd())),
+	}
+}
+
+func (p *setupProgress) elapsed() string {
+	return time.Since(p.start).Truncate(time.Second).String()
+}
+
+// print status when changed, and refresh the spinner
+func (p *setupProgress) update(status string) {
+	if status != p.status {
+		p.clear()
+		p.status = status
+		logger.Normal(fmt.Sprintf("[%s] Machine %d status: %s", p.elapsed(), p.serverID, status))
+	}
+
+	p.tick()
+}
+
+// print unknown status once, the wait continue until a known status or timeout
+func (p *setupProgress) unknown(status string) {
+	if p.unknownStatus == status {
+		return
+	}
+
+	p.unknownStatus = status
+	p.clear()
+	logger.Error(fmt.Sprintf("[%s] Unknown status %q of machine %d, waiting until it changes or timeout exceeded", p.elapsed(), status, p.serverID))
+	p.tick()
+}
+
+// print temporary problem, polling will be continued
+func (p *setupProgress) warn(err error) {
+	p.clear()
+	logger.Error(fmt.Sprintf("[%s] Failed to get machine %d setup progress, retrying: %s", p.elapsed(), p.serverID, err))
+	p.tick()
+}
+
+func (p *setupProgress) tick() {
+	if !p.spinner {
+		return
+	}
+
+	p.frame = (p.frame + 1) % len(spinnerFrames)
+	fmt.Fprintf(os.Stderr, "\r%s Waiting for machine %d setup (%s)", spinnerFrames[p.frame], p.serverID, p.elapsed())
+}
+
+// remove spinner line, so the next message start from empty line
+func (p *setupProgress) clear() {
+	if !p.spinner {
+		return
+	}
+
+	fmt.Fprint(os.Stderr, "\r\033[K")
+}
+
+type machineLogRecord struct {
+	Time    time.Time `json:"time" yaml:"time"`
+	Job     string    `json:"job,omitempty" yaml:"job,omitempty"`
+	Level   string    `json:"level,omitempty" yaml:"level,omitempty"`
+	Message string    `json:"message" yaml:"message"`
+}
+
+func newMachineLogRecord(log api.ServerLog) machineLogRecord {
+	var record = machineLogRecord{
+		Time:    log.CreatedAt.Local(),
+		Job:     log.Job,
+		Level:   log.Level,
+		Message: strings.TrimRight(log.Message, "\n"),
+	}
+
+	// log without timestamp is shown with time it was received
+	if log.CreatedAt.IsZero() {
+		record.Time = time.Now()
+	}
+
+	return record
+}
+
+func (r machineLogRecord) String() string {
+	var prefix = r.Time.Format(time.RFC3339)
+	if r.Job != "" {
+		prefix += " [" + r.Job + "]"
+	}
+	if r.Level != "" {
+		prefix += " " + strings.ToUpper(r.Level)
+	}
+
+	return prefix + " " + r.Message
+}
+
+// print server logs newer than the last printed log
+type logFollower struct {
+	client   *api.Client
+	serverID uint64
+	lastID   uint64
+	print    func(record machineLogRecord) error
+}
+
+// print logs as command result
+func printLogStream(record machineLogRecord) error {
+	return output.PrintStream(record)
+}
+
+// print logs as progress message, command result is printed after
+func printLogMessage(record machineLogRecord) error {
+	logger.Normal(record.String())
+	return nil
+}
+
+func (f *logFollower) poll(ctx context.Context) error {
+	logs, err := f.client.GetServerLogsContext(ctx, f.serverID, f.lastID)
+	if err != nil {
+		return err
+	}
+
+	for _, log := range logs.Data.Logs {
+		err = f.print(newMachineLogRecord(log))
+		if err != nil {
+			return err
+		}
+
+		if log.ID > f.lastID {
+			f.lastID = log.ID
+		}
+	}
+
+	return nil
+}
+
+// poll new logs every interval until ctx canceled, which is the way to stop following logs.
+// Temporary network and server error is printed and the polling continued.
+func (f *logFollower) follow(ctx context.Context, interval time.Duration) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+
+		err := f.poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if code := exitCode(err); code != exitNetwork && !api.IsServerError(err) {
+				return err
+			}
+			logger.Error(fmt.Sprintf("Failed to get logs, retrying: %s", err))
+		}
+	}
+}
+
+// poll machine detail until setup finished, failed, or timeout exceeded.
+// Setup logs are printed while waiting when logs is not nil.
+func waitSetup(ctx context.Context, client *api.Client, serverID uint64, timeout, interval time.Duration, logs *logFollower) (*dmachine.ResponseForPrivate, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var progress = newSetupProgress(serverID)
+	defer progress.clear()
+
+	for {
+		if logs != nil {
+			progress.clear()
+			err := logs.poll(waitCtx)
+			if err != nil && waitCtx.Err() == nil {
+				progress.warn(fmt.Errorf("getting logs: %w", err))
+			}
+		}
+
+		server, err := client.GetServerContext(waitCtx, serverID)
+		switch {
+		case err == nil:
+			var status = fmt.Sprint(server.Data.Status)
+			progress.update(status)
+
+			state, known := machineSetupState(status)
+			if !known {
+				progress.unknown(status)
+			}
+			if state != setupRunning && logs != nil {
+				// logs written between the last poll and the end of setup
+				progress.clear()
+				_ = logs.poll(waitCtx)
+			}
+
+			switch state {
+			case setupReady:
+				return &server.Data, nil
+			case setupFailed:
+				progress.clear()
+				return nil, newExitError(exitAPI, "Setup of machine %d failed with status %s, use command 'dnocs machine logs %d' to see the reason", serverID, status, serverID)
+			}
+		case ctx.Err() != nil:
+			return nil, stepError(ctx, fmt.Sprintf("waiting for machine %d setup", serverID), err)
+		case waitCtx.Err() != nil:
+			// timeout exceeded while requesting, reported below
+		case api.IsNotFound(err) || api.IsUnauthorized(err) || api.IsValidation(err):
+			return nil, stepError(ctx, fmt.Sprintf("getting machine %d", serverID), err)
+		default:
+			progress.warn(err)
+		}
+
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return nil, stepError(ctx, fmt.Sprintf("waiting for machine %d setup", serverID), ctx.Err())
+			}
+
+			progress.clear()
+			return nil, newExitError(exitTimeout, "Timed out after %s waiting for machine %d setup, last status: %s", timeout, serverID, progress.status)
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel/pkg/dmachine"
+)
+
+func TestMachineSetupState(t *testing.T) {
+	tests := []struct {
+		status    string
+		want      setupState
+		wantKnown bool
+	}{
+		{status: "pending", want: setupRunning, wantKnown: true},
+		{status: "Installing", want: setupRunning, wantKnown: true},
+		{status: " ready ", want: setupReady, wantKnown: true},
+		{status: "ACTIVE", want: setupReady, wantKnown: true},
+		{status: "failed", want: setupFailed, wantKnown: true},
+		{status: "error", want: setupFailed, wantKnown: true},
+		// substring of known status is not matched
+		{status: "not ready", want: setupRunning},
+		{status: "retry after failure", want: setupRunning},
+		{status: "", want: setupRunning},
+	}
+
+	for _, tt := range tests {
+		state, known := machineSetupState(tt.status)
+		if state != tt.want || known != tt.wantKnown {
+			t.Errorf("machineSetupState(%q) = %v, %v, want %v, %v", tt.status, state, known, tt.want, tt.wantKnown)
+		}
+	}
+}
+
+func TestWaitSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		timeout  time.Duration
+		wantCode int
+		wantErr  string
+	}{
+		{name: "ready", statuses: []string{"pending", "installing", "ready"}, timeout: 5 * time.Second, wantCode: exitOK},
+		{name: "failed", statuses: []string{"pending", "failed"}, timeout: 5 * time.Second, wantCode: exitAPI, wantErr: "failed with status failed"},
+		{name: "timeout", statuses: []string{"pending"}, timeout: 100 * time.Millisecond, wantCode: exitTimeout, wantErr: "last status: pending"},
+		{name: "unknown status until timeout", statuses: []string{"provisioning-failed-once"}, timeout: 100 * time.Millisecond, wantCode: exitTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeDPanel(t, dmachine.ResponseForPrivate{ID: 42, Address: "10.0.0.42"})
+			fake.setStatuses(42, tt.statuses...)
+
+			server, err := waitSetup(context.Background(), api.NewClient(), 42, tt.timeout, 10*time.Millisecond, nil)
+			if exitCode(err) != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d, error = %v", exitCode(err), tt.wantCode, err)
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want %q", err, tt.wantErr)
+			}
+
+			if tt.wantCode == exitOK {
+				if server == nil || server.ID != 42 {
+					t.Fatalf("waitSetup() = %+v, want machine 42", server)
+				}
+				if got := fake.count("GET /api/v1/server/detail/42"); got != len(tt.statuses) {
+					t.Errorf("detail requests = %d, want %d", got, len(tt.statuses))
+				}
+			}
+		})
+	}
+}
+
+func TestWaitSetupMachineNotFound(t *testing.T) {
+	newFakeDPanel(t)
+
+	_, err := waitSetup(context.Background(), api.NewClient(), 42, 5*time.Second, 10*time.Millisecond, nil)
+	if err == nil || exitCode(err) == exitTimeout {
+		t.Fatalf("waitSetup() error = %v, want error before timeout", err)
+	}
+}
+
+func TestWaitSetupCanceled(t *testing.T) {
+	fake := newFakeDPanel(t, dmachine.ResponseForPrivate{ID: 42})
+	fake.setStatuses(42, "pending")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := waitSetup(ctx, api.NewClient(), 42, 5*time.Second, 10*time.Millisecond, nil)
+	if exitCode(err) != exitInterrupted {
+		t.Fatalf("exit code = %d, want %d, error = %v", exitCode(err), exitInterrupted, err)
+	}
+}
+
+// logFollower of machine 42 which keep printed records
+func newTestLogFollower(t *testing.T) (*logFollower, *[]machineLogRecord, *sync.Mutex) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var printed []machineLogRecord
+
+	return &logFollower{
+		client:   api.NewClient(),
+		serverID: 42,
+		print: func(record machineLogRecord) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			printed = append(printed, record)
+			return nil
+		},
+	}, &printed, &mu
+}
+
+func TestLogFollowerPoll(t *testing.T) {
+	fake := newFakeDPanel(t, dmachine.ResponseForPrivate{ID: 42})
+	fake.addLogs(42,
+		api.ServerLog{ID: 1, Job: "setup", Message: "start"},
+		api.ServerLog{ID: 2, Job: "setup", Message: "install agent"},
+	)
+
+	logs, printed, _ := newTestLogFollower(t)
+
+	if err := logs.poll(context.Background()); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if len(*printed) != 2 || logs.lastID != 2 {
+		t.Fatalf("printed %d logs, cursor %d, want 2 logs and cursor 2", len(*printed), logs.lastID)
+	}
+
+	// only new log printed
+	fake.addLogs(42, api.ServerLog{ID: 3, Job: "setup", Message: "done"})
+	if err := logs.poll(context.Background()); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if len(*printed) != 3 || (*printed)[2].Message != "done" || logs.lastID != 3 {
+		t.Fatalf("printed %+v, cursor %d, want log 3 printed once", *printed, logs.lastID)
+	}
+
+	// nothing new
+	if err := logs.poll(context.Background()); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if len(*printed) != 3 || logs.lastID != 3 {
+		t.Errorf("printed %d logs, cursor %d after poll without new log, want 3 and 3", len(*printed), logs.lastID)
+	}
+}
+
+func TestNewMachineLogRecord(t *testing.T) {
+	var created = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	record := newMachineLogRecord(api.ServerLog{ID: 1, Job: "setup", Level: "error", Message: "apt failed\n", CreatedAt: created})
+	if !record.Time.Equal(created) || record.Time.Location() != time.Local {
+		t.Errorf("Time = %s, want %s in local time", record.Time, created)
+	}
+	if want := created.Local().Format(time.RFC3339) + " [setup] ERROR apt failed"; record.String() != want {
+		t.Errorf("String() = %q, want %q", record.String(), want)
+	}
+
+	// log without timestamp shown with time it was received
+	before := time.Now()
+	record = newMachineLogRecord(api.ServerLog{ID: 2, Message: "no time"})
+	if record.Time.Before(before) || record.Time.After(time.Now()) {
+		t.Errorf("Time = %s, want time it was received", record.Time)
+	}
+	if !strings.HasSuffix(record.String(), " no time") || strings.Contains(record.String(), "[") {
+		t.Errorf("String() = %q, want time and message only", record.String())
+	}
+}
+
+func TestLogFollowerFollow(t *testing.T) {
+	fake := newFakeDPanel(t, dmachine.ResponseForPrivate{ID: 42})
+	logs, printed, mu := newTestLogFollower(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var done = make(chan error, 1)
+	go func() {
+		done <- logs.follow(ctx, 10*time.Millisecond)
+	}()
+
+	fake.addLogs(42, api.ServerLog{ID: 1, Message: "first"})
+	time.Sleep(50 * time.Millisecond)
+	fake.addLogs(42, api.ServerLog{ID: 2, Message: "second"})
+
+	// wait until both logs printed
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		count := len(*printed)
+		mu.Unlock()
+
+		if count >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("follow() error = %v after cancel, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("follow() did not stop after cancel")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(*printed) != 2 || (*printed)[0].Message != "first" || (*printed)[1].Message != "second" {
+		t.Errorf("printed %+v, want first and second once", *printed)
+	}
+}
+
+func TestLogFollowerFollowMachineDeleted(t *testing.T) {
+	newFakeDPanel(t)
+	logs, _, _ := newTestLogFollower(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := logs.follow(ctx, 10*time.Millisecond)
+	if !api.IsNotFound(err) {
+		t.Fatalf("follow() error = %v, want not found", err)
+	}
+}
@@ -1,12 +1,28 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
+	"github.com/devetek/d-panel-cli/internal/tunnel"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
+var (
+	// context name from global --context flag
+	contextName string
+	// output format from global --output flag
+	outputFormat string
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "dnocs",
 	Short: "dnocs ID CLI",
@@ -14,7 +30,54 @@ var rootCmd = &cobra.Command{
 Simplify the process of managing resource such as user, machine, and application in dPanel (Devetek Panel).
 
 Full documentation is available at: https://cloud.terpusat.com/docs/
+
+Exit codes:
+  0    success
+  1    unclassified error
+  2    invalid flag, argument or input
+  3    login required, no session or session expired
+  4    dPanel API returned an error
+  5    dPanel or other remote service unreachable
+  6    permission error, e.g. command require root or sudo
+  7    partial success, some steps finished before the command failed
+  8    timed out waiting for dPanel, e.g. machine setup
+  9    one or more checks of 'dnocs doctor' failed
+  130  interrupted by Ctrl+C
 `,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		err := output.SetFormat(outputFormat)
+		if err != nil {
+			return validationError("%s", err)
+		}
+
+		// keep stdout parsable, human message written to stderr
+		if output.IsStructured() {
+			logger.SetOutput(os.Stderr)
+		}
+
+		// select context for this command only, and apply its tunnel server
+		api.SelectProfile(contextName)
+
+		// only warn, so version and context commands can still run to repair the configuration.
+		// Command using dPanel API fail later with the same error.
+		profile, err := api.CurrentProfile()
+		if err != nil {
+			logger.Error(fmt.Sprintf("Invalid configuration in ~/.devetek/config.json, using default tunnel server: %s", err))
+			return nil
+		}
+
+		if profile.TunnelHost != "" {
+			tunnel.TunnelHost = profile.TunnelHost
+		}
+
+		if profile.TunnelPort != "" {
+			tunnel.TunnelPort = profile.TunnelPort
+		}
+
+		return nil
+	},
 }
 
 func init() {
@@ -24,10 +87,21 @@ func init() {
 	}
 	defer logger.Sync()
 
+	rootCmd.PersistentFlags().StringVarP(&contextName, "context", "", os.Getenv("DNOCS_CONTEXT"), "Use this context instead of the current context")
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return validationError("%s, see '%s --help'", err, cmd.CommandPath())
+	})
+
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", string(output.FormatTable), "Output format: table, json or yaml")
+
 	rootCmd.AddCommand(
 		NewAuthCmd(logger).Connect(),
 		NewTunnelCmd(logger).Connect(),
 		NewMachineCmd(logger).Connect(),
+		NewContextCmd(logger).Connect(),
+		NewRouterCmd(logger).Connect(),
+		NewSecretCmd(logger).Connect(),
+		NewDoctorCmd(logger).Connect(),
 		versionCmd(),
 		systemInfoCmd(),
 	)
@@ -35,7 +109,23 @@ func init() {
 
 func Execute() {
 	rootCmd.Version = currentVersion
-	cobra.CheckErr(rootCmd.Execute())
+
+	// cancel running command on Ctrl+C, next signal will terminate immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// result written to stdout, error written to stderr
+	err := rootCmd.ExecuteContext(ctx)
+	if err != nil {
+		logger.Error(err.Error())
+		stop()
+		os.Exit(exitCode(err))
+	}
 }
 
 func main() {
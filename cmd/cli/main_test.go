@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// run dnocs with args, and return what it wrote to stdout.
+// Flags changed by args and the context kept by cobra are reset after, so every run start from the default values.
+func executeCLI(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout = os.Stdout
+	os.Stdout = writer
+
+	var captured = make(chan string)
+	go func() {
+		content, _ := io.ReadAll(reader)
+		captured <- string(content)
+	}()
+
+	rootCmd.SetArgs(args)
+	err = rootCmd.ExecuteContext(t.Context())
+
+	writer.Close()
+	os.Stdout = stdout
+	var result = <-captured
+
+	rootCmd.SetArgs(nil)
+	resetCommand(rootCmd)
+	_ = output.SetFormat(string(output.FormatTable))
+	logger.SetOutput(os.Stdout)
+
+	return result, err
+}
+
+func resetCommand(cmd *cobra.Command) {
+	var reset = func(flag *pflag.Flag) {
+		if flag.Changed {
+			_ = flag.Value.Set(flag.DefValue)
+			flag.Changed = false
+		}
+	}
+
+	cmd.PersistentFlags().VisitAll(reset)
+	cmd.Flags().VisitAll(reset)
+
+	// context of the finished test is canceled, cobra only set context of command without one
+	cmd.SetContext(nil)
+
+	for _, child := range cmd.Commands() {
+		resetCommand(child)
+	}
+}
+
+// broken configuration must not block commands used to inspect or repair it
+func TestRootBrokenConfigOnlyWarn(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+	}{
+		{name: "version with invalid JSON", config: `{"contexts": [`, args: []string{"version"}},
+		{name: "version with missing current context", config: `{"current_context":"gone"}`, args: []string{"version"}},
+		{name: "switch back to default context", config: `{"current_context":"gone"}`, args: []string{"context", "use", "default"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			t.Setenv("DNOCS_CONTEXT", "")
+			writeTestConfig(t, tt.config)
+
+			rootCmd.SetArgs(tt.args)
+			defer rootCmd.SetArgs(nil)
+
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("dnocs %v error = %v", tt.args, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
+	"github.com/devetek/d-panel/pkg/drouter"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type routerRecord struct {
+	ID        uint64 `json:"id" yaml:"id"`
+	Name      string `json:"name" yaml:"name"`
+	Type      string `json:"type" yaml:"type"`
+	Domain    string `json:"domain" yaml:"domain"`
+	MachineID uint64 `json:"machine_id" yaml:"machine_id"`
+	Upstream  string `json:"upstream" yaml:"upstream"`
+}
+
+type routerDeleteRecord struct {
+	ID      uint64 `json:"id" yaml:"id"`
+	Domain  string `json:"domain" yaml:"domain"`
+	Deleted bool   `json:"deleted" yaml:"deleted"`
+}
+
+func newRouterRecord(router drouter.ResponseRouter) routerRecord {
+	return routerRecord{
+		ID:        uint64(router.ID),
+		Name:      router.Name,
+		Type:      router.Type,
+		Domain:    router.Domain,
+		MachineID: uint64(router.MachineID),
+		Upstream:  router.Upstream,
+	}
+}
+
+// find router by ID, or by domain from list router
+func findRouter(ctx context.Context, client *api.Client, ref string) (*drouter.ResponseRouter, error) {
+	if routerID, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		router, err := client.GetRouterContext(ctx, routerID)
+		if err != nil {
+			if api.IsNotFound(err) {
+				return nil, validationError("Router %s not found", ref)
+			}
+			return nil, stepError(ctx, "getting router "+ref, err)
+		}
+
+		return &router.Data, nil
+	}
+
+	router, err := findRouterByDomain(ctx, client, ref)
+	if err != nil {
+		return nil, err
+	}
+	if router == nil {
+		return nil, validationError("Router %s not found", ref)
+	}
+
+	return router, nil
+}
+
+// find router by domain, router created by machine create --behind-tunnel has the same domain as the machine
+func findRouterByDomain(ctx context.Context, client *api.Client, domain string) (*drouter.ResponseRouter, error) {
+	domain = trimScheme(domain)
+
+	for page := 1; ; page++ {
+		routers, err := client.ListRoutersContext(ctx, api.ListRouterOptions{Page: page, Limit: 100})
+		if err != nil {
+			return nil, stepError(ctx, "getting list router", err)
+		}
+
+		for _, router := range routers.Data.Routers {
+			if trimScheme(router.Domain) == domain {
+				return &router, nil
+			}
+		}
+
+		if len(routers.Data.Routers) == 0 || page >= routers.Data.Pagination.TotalPage {
+			break
+		}
+	}
+
+	return nil, nil
+}
+
+// remove http:// or https:// from domain
+func trimScheme(domain string) string {
+	domain = strings.TrimPrefix(domain, "http://")
+	domain = strings.TrimPrefix(domain, "https://")
+
+	return strings.TrimSuffix(domain, "/")
+}
+
+type RouterCmd struct {
+	cmd       *cobra.Command
+	zapLogger *zap.Logger
+
+	// list pagination
+	page  int
+	limit int
+
+	// create option
+	name     string
+	domain   string
+	upstream string
+	machine  string
+}
+
+func NewRouterCmd(logger *zap.Logger) *RouterCmd {
+	return &RouterCmd{
+		zapLogger: logger,
+		cmd: &cobra.Command{
+			Use:   "router",
+			Short: "Manage dPanel HTTP router",
+		},
+	}
+}
+
+func (r *RouterCmd) Connect() *cobra.Command {
+	r.cmd.AddCommand(
+		r.list(),
+		r.create(),
+		r.get(),
+		r.delete(),
+	)
+
+	return r.cmd
+}
+
+func (r *RouterCmd) list() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List router in your dPanel account",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if r.page < 1 || r.limit < 1 {
+				return validationError("--page and --limit must be greater than 0")
+			}
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err := requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			routers, err := client.ListRoutersContext(ctx, api.ListRouterOptions{Page: r.page, Limit: r.limit})
+			if err != nil {
+				return stepError(ctx, "getting list router", err)
+			}
+
+			var records []routerRecord
+			for _, router := range routers.Data.Routers {
+				records = append(records, newRouterRecord(router))
+			}
+
+			err = output.Print(records)
+			if err != nil {
+				return err
+			}
+
+			var pagination = routers.Data.Pagination
+			if pagination.TotalPage > 1 {
+				logger.Normal(fmt.Sprintf("Page %d of %d, total %d router, use --page to see other page", r.page, pagination.TotalPage, pagination.TotalItem))
+			}
+
+			return nil
+		},
+	}
+
+	runCmd.PersistentFlags().IntVarP(&r.page, "page", "", 1, "Page number")
+	runCmd.PersistentFlags().IntVarP(&r.limit, "limit", "", 20, "Number of router per page")
+
+	return runCmd
+}
+
+func (r *RouterCmd) create() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "create",
+		Short: "Create proxy_pass router",
+		Long: `Create proxy_pass router in a machine, request to the domain is proxied to the upstream.
+
+Example:
+  dnocs router create --machine=42 --domain="app.example.com" --upstream="localhost:3000"`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			var domain = strings.ToLower(trimScheme(r.domain))
+			if !isValidDomain(domain) {
+				return validationError("Invalid domain %q, set valid domain with --domain", r.domain)
+			}
+
+			if r.upstream == "" {
+				return validationError("Upstream is required, e.g. --upstream=\"localhost:3000\"")
+			}
+
+			if r.machine == "" {
+				return validationError("Machine is required, set machine ID or name with --machine")
+			}
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err := requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			machine, err := findServer(ctx, client, r.machine)
+			if err != nil {
+				return err
+			}
+
+			var name = r.name
+			if name == "" {
+				name = domain
+			}
+
+			router, err := client.CreateRouterContext(ctx, drouter.PayloadRouter{
+				AdvanceMode: false,
+				Type:        "proxy_pass",
+				Name:        name,
+				Domain:      domain,
+				MachineID:   machine.ID,
+				Upstream:    r.upstream,
+			})
+			if err != nil {
+				return routerCreateError(ctx, client, domain, err)
+			}
+
+			logger.Success(fmt.Sprintf("Router %d created for %s", router.Data.ID, router.Data.Domain))
+
+			return output.Print(newRouterRecord(router.Data))
+		},
+	}
+
+	runCmd.PersistentFlags().StringVarP(&r.domain, "domain", "d", "", "Domain of the router, e.g. app.example.com")
+	runCmd.PersistentFlags().StringVarP(&r.upstream, "upstream", "u", "", "Upstream of proxy_pass, e.g. localhost:3000")
+	runCmd.PersistentFlags().StringVarP(&r.machine, "machine", "m", "", "ID or name of machine serving the router")
+	runCmd.PersistentFlags().StringVarP(&r.name, "name", "n", "", "Name of the router, default to the domain")
+
+	return runCmd
+}
+
+func (r *RouterCmd) get() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "get <id|domain>",
+		Short: "Show router detail",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err := requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			router, err := findRouter(ctx, client, args[0])
+			if err != nil {
+				return err
+			}
+
+			return output.Print(newRouterRecord(*router))
+		},
+	}
+
+	return runCmd
+}
+
+func (r *RouterCmd) delete() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "delete <id|domain>",
+		Short: "Delete router",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err := requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			router, err := findRouter(ctx, client, args[0])
+			if err != nil {
+				return err
+			}
+
+			_, err = client.DeleteRouterContext(ctx, uint64(router.ID))
+			if err != nil {
+				return stepError(ctx, fmt.Sprintf("deleting router %d", router.ID), err)
+			}
+
+			logger.Success(fmt.Sprintf("Router %d (%s) deleted", router.ID, router.Domain))
+
+			return output.Print(routerDeleteRecord{ID: uint64(router.ID), Domain: router.Domain, Deleted: true})
+		},
+	}
+
+	return runCmd
+}
+
+// explain failed router creation, domain already used by other router is the common cause
+func routerCreateError(ctx context.Context, client *api.Client, domain string, err error) error {
+	if ctx.Err() != nil {
+		return stepError(ctx, "creating router", err)
+	}
+
+	if existing, findErr := findRouterByDomain(ctx, client, domain); findErr == nil && existing != nil {
+		return newExitError(exitCode(err), "Domain %s already used by router %d, delete it with command 'dnocs router delete %d': %w", domain, existing.ID, existing.ID, err)
+	}
+
+	return stepError(ctx, "creating router", err)
+}
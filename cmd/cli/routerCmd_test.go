@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel/pkg/dmachine"
+	"github.com/devetek/d-panel/pkg/drouter"
+)
+
+func TestTrimScheme(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{domain: "app.example.com", want: "app.example.com"},
+		{domain: "http://app.example.com", want: "app.example.com"},
+		{domain: "https://app.example.com/", want: "app.example.com"},
+		{domain: "https://app.example.com:8443", want: "app.example.com:8443"},
+		{domain: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := trimScheme(tt.domain); got != tt.want {
+			t.Errorf("trimScheme(%q) = %q, want %q", tt.domain, got, tt.want)
+		}
+	}
+}
+
+// routers spread over 2 pages of the 100 routers fetched by findRouterByDomain
+func testRouters() []drouter.ResponseRouter {
+	var routers []drouter.ResponseRouter
+	for i := 1; i <= 150; i++ {
+		routers = append(routers, drouter.ResponseRouter{ID: uint(i), Domain: "app-" + strings.Repeat("x", i%5) + "-" + string(rune('a'+i%26)) + ".example.com"})
+	}
+	routers[139].Domain = "https://last-page.example.com"
+
+	return routers
+}
+
+func TestFindRouterByDomain(t *testing.T) {
+	tests := []struct {
+		name   string
+		domain string
+		wantID uint
+	}{
+		{name: "router on the second page", domain: "last-page.example.com", wantID: 140},
+		{name: "domain with scheme", domain: "http://last-page.example.com/", wantID: 140},
+		{name: "not found", domain: "missing.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeDPanel(t)
+			fake.routers = testRouters()
+
+			router, err := findRouterByDomain(context.Background(), api.NewClient(), tt.domain)
+			if err != nil {
+				t.Fatalf("findRouterByDomain() error = %v", err)
+			}
+
+			var gotID uint
+			if router != nil {
+				gotID = router.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("findRouterByDomain() = router %d, want %d", gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestRouterCreateError(t *testing.T) {
+	var createErr = &api.APIError{Method: http.MethodPost, Path: "/api/v1/router/create", StatusCode: http.StatusBadRequest, Message: "domain already exist"}
+
+	t.Run("domain used by other router", func(t *testing.T) {
+		fake := newFakeDPanel(t)
+		fake.routers = testRouters()
+
+		err := routerCreateError(context.Background(), api.NewClient(), "last-page.example.com", createErr)
+		if exitCode(err) != exitValidation {
+			t.Fatalf("exit code = %d, want %d, error = %v", exitCode(err), exitValidation, err)
+		}
+		if !strings.Contains(err.Error(), "already used by router 140") || !strings.Contains(err.Error(), "dnocs router delete 140") {
+			t.Errorf("error = %q, want router 140 and how to delete it", err)
+		}
+		if !api.IsValidation(err) {
+			t.Errorf("error = %q does not wrap the API error", err)
+		}
+	})
+
+	t.Run("other error", func(t *testing.T) {
+		newFakeDPanel(t)
+
+		err := routerCreateError(context.Background(), api.NewClient(), "new.example.com", createErr)
+		if exitCode(err) != exitValidation || !strings.Contains(err.Error(), "Error creating router") {
+			t.Errorf("error = %v, exit code %d, want creating router error with exit code %d", err, exitCode(err), exitValidation)
+		}
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		newFakeDPanel(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := routerCreateError(ctx, api.NewClient(), "new.example.com", context.Canceled)
+		if exitCode(err) != exitInterrupted {
+			t.Errorf("exit code = %d, want %d", exitCode(err), exitInterrupted)
+		}
+	})
+}
+
+func TestRouterCreate(t *testing.T) {
+	fake := newFakeDPanel(t, dmachine.ResponseForPrivate{ID: 42, Address: "10.0.0.42"})
+	fake.routers = []drouter.ResponseRouter{{ID: 7, Domain: "used.example.com"}}
+
+	stdout, err := executeCLI(t, "router", "create", "--machine=42", "--domain=https://App.example.com", "--upstream=localhost:3000", "-o", "json")
+	if err != nil {
+		t.Fatalf("router create error = %v", err)
+	}
+
+	var payload drouter.PayloadRouter
+	if err := json.Unmarshal(fake.bodies["POST /api/v1/router/create"], &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Domain != "app.example.com" || payload.MachineID != 42 || payload.Type != "proxy_pass" || payload.Upstream != "localhost:3000" {
+		t.Errorf("payload = %+v", payload)
+	}
+
+	var record routerRecord
+	if err := json.Unmarshal([]byte(stdout), &record); err != nil {
+		t.Fatalf("stdout %q is not a router record: %v", stdout, err)
+	}
+	if record.Domain != "app.example.com" || record.MachineID != 42 {
+		t.Errorf("record = %+v", record)
+	}
+
+	// domain already used
+	_, err = executeCLI(t, "router", "create", "--machine=42", "--domain=used.example.com", "--upstream=localhost:3000")
+	if exitCode(err) != exitValidation || !strings.Contains(err.Error(), "already used by router 7") {
+		t.Errorf("error = %v, exit code %d, want domain used by router 7", err, exitCode(err))
+	}
+}
+
+func TestRouterGetAndDelete(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  string
+	}{
+		{name: "by ID", ref: "140"},
+		{name: "by domain", ref: "last-page.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeDPanel(t)
+			fake.routers = testRouters()
+
+			stdout, err := executeCLI(t, "router", "get", tt.ref, "-o", "json")
+			if err != nil {
+				t.Fatalf("router get error = %v", err)
+			}
+
+			var router routerRecord
+			if err := json.Unmarshal([]byte(stdout), &router); err != nil || router.ID != 140 {
+				t.Fatalf("router get stdout = %q, want router 140", stdout)
+			}
+
+			stdout, err = executeCLI(t, "router", "delete", tt.ref, "-o", "json")
+			if err != nil {
+				t.Fatalf("router delete error = %v", err)
+			}
+
+			var deleted routerDeleteRecord
+			if err := json.Unmarshal([]byte(stdout), &deleted); err != nil {
+				t.Fatalf("router delete stdout %q is not a record: %v", stdout, err)
+			}
+			if deleted != (routerDeleteRecord{ID: 140, Domain: "https://last-page.example.com", Deleted: true}) {
+				t.Errorf("record = %+v", deleted)
+			}
+
+			if !fake.received("DELETE /api/v1/router/delete/140") {
+				t.Error("router 140 was not deleted")
+			}
+
+			_, err = executeCLI(t, "router", "get", "140")
+			if exitCode(err) != exitValidation || !strings.Contains(err.Error(), "Router 140 not found") {
+				t.Errorf("router get after delete error = %v, want not found", err)
+			}
+		})
+	}
+}
+
+func TestRouterDeleteYAML(t *testing.T) {
+	fake := newFakeDPanel(t)
+	fake.routers = []drouter.ResponseRouter{{ID: 7, Domain: "app.example.com"}}
+
+	stdout, err := executeCLI(t, "router", "delete", "7", "-o", "yaml")
+	if err != nil {
+		t.Fatalf("router delete error = %v", err)
+	}
+
+	for _, want := range []string{"id: 7", "domain: app.example.com", "deleted: true"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout = %q, want %q", stdout, want)
+		}
+	}
+}
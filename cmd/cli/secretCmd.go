@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel-cli/internal/helper"
+	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
+	"github.com/devetek/d-panel/pkg/dsecret"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type secretRecord struct {
+	ID   uint64 `json:"id" yaml:"id"`
+	Name string `json:"name" yaml:"name"`
+	Type string `json:"type" yaml:"type"`
+	// SHA256 fingerprint, same as 'ssh-keygen -lf'
+	Fingerprint string `json:"fingerprint,omitempty" yaml:"fingerprint,omitempty"`
+	PublicKey   string `json:"public_key,omitempty" yaml:"public_key,omitempty"`
+}
+
+type secretDeleteRecord struct {
+	ID      uint64 `json:"id" yaml:"id"`
+	Name    string `json:"name" yaml:"name"`
+	Deleted bool   `json:"deleted" yaml:"deleted"`
+}
+
+func newSecretRecord(secret dsecret.Response) secretRecord {
+	var record = secretRecord{
+		ID:        uint64(secret.ID),
+		Name:      secret.Name,
+		Type:      secret.Type,
+		PublicKey: strings.TrimSpace(secret.Data.Data()["public"]),
+	}
+	record.Fingerprint = helper.SSHFingerprint(record.PublicKey)
+
+	return record
+}
+
+// find secret ssh by ID, or by name from list secret ssh, and return its detail
+func findSecretSSH(ctx context.Context, client *api.Client, ref string) (*dsecret.Response, error) {
+	if _, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		return findSecretSSHByID(ctx, client, ref)
+	}
+
+	return findSecretSSHByName(ctx, client, ref)
+}
+
+// get detail secret ssh by ID
+func findSecretSSHByID(ctx context.Context, client *api.Client, secretID string) (*dsecret.Response, error) {
+	if _, err := strconv.ParseUint(secretID, 10, 64); err != nil {
+		return nil, validationError("Invalid secret ssh ID %q", secretID)
+	}
+
+	secret, err := client.GetSecretSSHByIDContext(ctx, secretID)
+	if err != nil {
+		if api.IsNotFound(err) {
+			return nil, validationError("Secret ssh %s not found", secretID)
+		}
+		return nil, stepError(ctx, "getting detail secret ssh "+secretID, err)
+	}
+
+	return &secret.Data, nil
+}
+
+// get detail secret ssh by name from every page of list secret ssh
+func findSecretSSHByName(ctx context.Context, client *api.Client, name string) (*dsecret.Response, error) {
+	var seen int
+	for page := 1; ; page++ {
+		secrets, err := client.ListSecretSSHContext(ctx, api.ListSecretSSHOptions{Page: page, Limit: 100})
+		if err != nil {
+			return nil, stepError(ctx, "getting list secret ssh", err)
+		}
+
+		for _, secret := range secrets.Data.Secrets {
+			if secret.Name == name {
+				return findSecretSSHByID(ctx, client, fmt.Sprint(secret.ID))
+			}
+		}
+
+		// list secret ssh only report total item
+		seen += len(secrets.Data.Secrets)
+		if len(secrets.Data.Secrets) == 0 || seen >= secrets.Data.Pagination.TotalItem {
+			break
+		}
+	}
+
+	return nil, validationError("Secret ssh %s not found", name)
+}
+
+// SSH key used by dPanel to access this machine: by ID or name when set,
+// else the first key of the account, a new key is created when the account has none
+func selectSecretSSH(ctx context.Context, client *api.Client, secretID, secretName string) (*dsecret.Response, error) {
+	switch {
+	case secretID != "":
+		return findSecretSSHByID(ctx, client, secretID)
+	case secretName != "":
+		return findSecretSSHByName(ctx, client, secretName)
+	}
+
+	// get list secret ssh
+	secretSSH, err := client.GetListSecretSSHContext(ctx)
+	if err != nil {
+		return nil, stepError(ctx, "getting list secret ssh", err)
+	}
+
+	if secretSSH.Data.Pagination.TotalItem == 0 || len(secretSSH.Data.Secrets) == 0 {
+		// create new SSH key
+		newSSHKey, err := client.CreateSecretSSHContext(ctx, api.CreateSecretSSHOptions{})
+		if err != nil {
+			return nil, stepError(ctx, "creating secret ssh", err)
+		}
+
+		return &newSSHKey.Data, nil
+	}
+
+	// get detail of the first secret ssh from existing
+	detailSSHKey, err := client.GetSecretSSHByIDContext(ctx, fmt.Sprintf("%d", secretSSH.Data.Secrets[0].ID))
+	if err != nil {
+		return nil, stepError(ctx, "getting detail secret ssh", err)
+	}
+
+	return &detailSSHKey.Data, nil
+}
+
+// RSA key size supported by dPanel
+func validateKeySize(keySize int) error {
+	switch keySize {
+	case 2048, 3072, 4096:
+		return nil
+	}
+
+	return validationError("Invalid --key-size %d, use 2048, 3072 or 4096", keySize)
+}
+
+// machines which use the secret to be accessed by dPanel
+func machinesUsingSecret(ctx context.Context, client *api.Client, secretID uint64) ([]string, error) {
+	var machines []string
+	for page := 1; ; page++ {
+		servers, err := client.ListServersContext(ctx, api.ListServerOptions{Page: page, Limit: 100})
+		if err != nil {
+			return nil, stepError(ctx, "getting list machine", err)
+		}
+
+		for _, server := range servers.Data.Servers {
+			if uint64(server.SecretID) == secretID {
+				machines = append(machines, fmt.Sprint(server.ID))
+			}
+		}
+
+		if len(servers.Data.Servers) == 0 || page >= servers.Data.Pagination.TotalPage {
+			break
+		}
+	}
+
+	return machines, nil
+}
+
+type SecretCmd struct {
+	cmd       *cobra.Command
+	zapLogger *zap.Logger
+
+	// create option
+	name    string
+	keySize int
+
+	// import option
+	publicKeyFile  string
+	privateKeyFile string
+
+	// print public key only, e.g. to append it to authorized_keys
+	publicKey bool
+	// delete secret even when used by machine
+	force bool
+}
+
+func NewSecretCmd(logger *zap.Logger) *SecretCmd {
+	return &SecretCmd{
+		zapLogger: logger,
+		cmd: &cobra.Command{
+			Use:   "secret",
+			Short: "Manage dPanel secret",
+		},
+	}
+}
+
+func (s *SecretCmd) Connect() *cobra.Command {
+	var sshCmd = &cobra.Command{
+		Use:   "ssh",
+		Short: "Manage SSH key used by dPanel to access your machine",
+	}
+
+	sshCmd.AddCommand(
+		s.list(),
+		s.create(),
+		s.importKey(),
+		s.show(),
+		s.delete(),
+	)
+
+	s.cmd.AddCommand(sshCmd)
+
+	return s.cmd
+}
+
+func (s *SecretCmd) list() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List SSH key in your dPanel account",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err := requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			secrets, err := client.GetListSecretSSHContext(ctx)
+			if err != nil {
+				return stepError(ctx, "getting list secret ssh", err)
+			}
+
+			var records []secretRecord
+			for _, secret := range secrets.Data.Secrets {
+				var record = newSecretRecord(secret)
+				// list does not contain the key, use show to get it
+				record.PublicKey = ""
+				record.Fingerprint = ""
+				records = append(records, record)
+			}
+
+			return output.Print(records)
+		},
+	}
+
+	return runCmd
+}
+
+func (s *SecretCmd) create() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "create",
+		Short: "Create new SSH key, generated by dPanel",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			err := validateKeySize(s.keySize)
+			if err != nil {
+				return err
+			}
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err = requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			secret, err := client.CreateSecretSSHContext(ctx, api.CreateSecretSSHOptions{
+				Name:    s.name,
+				KeySize: s.keySize,
+			})
+			if err != nil {
+				return stepError(ctx, "creating secret ssh", err)
+			}
+
+			logger.Success(fmt.Sprintf("Secret ssh %d (%s) created", secret.Data.ID, secret.Data.Name))
+
+			return output.Print(newSecretRecord(secret.Data))
+		},
+	}
+
+	runCmd.PersistentFlags().StringVarP(&s.name, "name", "n", "", "Name of the SSH key (default cli-ssh-key-<date time>)")
+	runCmd.PersistentFlags().IntVarP(&s.keySize, "key-size", "", api.DefaultSSHKeySize, "Size of RSA key, 2048, 3072 or 4096")
+
+	return runCmd
+}
+
+func (s *SecretCmd) importKey() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "import",
+		Short: "Upload existing SSH key to dPanel",
+		Long: `Upload existing SSH key pair to dPanel, instead of generating new key.
+Public key is derived from the private key when --public-key-file not set.
+
+Example:
+  dnocs secret ssh import --name="team-key" --private-key-file ~/.ssh/team_ed25519 --public-key-file ~/.ssh/team_ed25519.pub`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if s.name == "" {
+				return validationError("Name is required, set it with --name")
+			}
+
+			privateKey, err := os.ReadFile(s.privateKeyFile)
+			if err != nil {
+				return fmt.Errorf("Error reading private key: %w", err)
+			}
+
+			var publicKey []byte
+			if s.publicKeyFile != "" {
+				publicKey, err = os.ReadFile(s.publicKeyFile)
+				if err != nil {
+					return fmt.Errorf("Error reading public key: %w", err)
+				}
+			}
+
+			authorizedKey, err := helper.ValidateSSHKeyPair(privateKey, publicKey)
+			if err != nil {
+				return validationError("%s", err)
+			}
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err = requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			secret, err := client.ImportSecretSSHContext(ctx, api.ImportSecretSSHPayload{
+				Name:       s.name,
+				PublicKey:  authorizedKey,
+				PrivateKey: string(privateKey),
+			})
+			if err != nil {
+				return stepError(ctx, "importing secret ssh", err)
+			}
+
+			logger.Success(fmt.Sprintf("Secret ssh %d (%s) imported", secret.Data.ID, secret.Data.Name))
+
+			var record = newSecretRecord(secret.Data)
+			if record.PublicKey == "" {
+				record.PublicKey = authorizedKey
+				record.Fingerprint = helper.SSHFingerprint(authorizedKey)
+			}
+
+			return output.Print(record)
+		},
+	}
+
+	runCmd.PersistentFlags().StringVarP(&s.name, "name", "n", "", "Name of the SSH key")
+	runCmd.PersistentFlags().StringVarP(&s.privateKeyFile, "private-key-file", "", "", "Private key file, e.g. ~/.ssh/id_ed25519")
+	runCmd.PersistentFlags().StringVarP(&s.publicKeyFile, "public-key-file", "", "", "Public key file, e.g. ~/.ssh/id_ed25519.pub (optional)")
+	_ = runCmd.MarkPersistentFlagRequired("private-key-file")
+
+	return runCmd
+}
+
+func (s *SecretCmd) show() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "show <id|name>",
+		Short: "Show SSH key and its public key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err := requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			secret, err := findSecretSSH(ctx, client, args[0])
+			if err != nil {
+				return err
+			}
+
+			var record = newSecretRecord(*secret)
+			if s.publicKey {
+				fmt.Println(record.PublicKey)
+				return nil
+			}
+
+			return output.Print(record)
+		},
+	}
+
+	runCmd.PersistentFlags().BoolVarP(&s.publicKey, "public-key", "", false, "Print public key only, in authorized_keys format")
+
+	return runCmd
+}
+
+func (s *SecretCmd) delete() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "delete <id|name>",
+		Short: "Delete SSH key",
+		Long:  `Delete SSH key from dPanel, key used by a machine is only deleted with --force, dPanel can not access the machine after that.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// init dPanel client
+			client := api.NewClient()
+
+			// check if session exist
+			err := requireSession(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			secret, err := findSecretSSH(ctx, client, args[0])
+			if err != nil {
+				return err
+			}
+
+			if !s.force {
+				machines, err := machinesUsingSecret(ctx, client, uint64(secret.ID))
+				if err != nil {
+					return err
+				}
+
+				if len(machines) > 0 {
+					return validationError("Secret ssh %d is used by machine %s, use 'dnocs machine rotate-key' in the machine first, or --force to delete it", secret.ID, strings.Join(machines, ", "))
+				}
+			}
+
+			_, err = client.DeleteSecretSSHContext(ctx, fmt.Sprint(secret.ID))
+			if err != nil {
+				return stepError(ctx, fmt.Sprintf("deleting secret ssh %d", secret.ID), err)
+			}
+
+			logger.Success(fmt.Sprintf("Secret ssh %d (%s) deleted", secret.ID, secret.Name))
+
+			return output.Print(secretDeleteRecord{ID: uint64(secret.ID), Name: secret.Name, Deleted: true})
+		},
+	}
+
+	runCmd.PersistentFlags().BoolVarP(&s.force, "force", "", false, "Delete even when the key is used by a machine")
+
+	return runCmd
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devetek/d-panel-cli/internal/api"
+	"github.com/devetek/d-panel/pkg/dmachine"
+	"github.com/devetek/d-panel/pkg/dsecret"
+	"golang.org/x/crypto/ssh"
+)
+
+// write ed25519 key pair in a temp folder, and return the private key file, the public key file,
+// and the public key in authorized_keys format
+func writeTestKeyPair(t *testing.T, passphrase string) (string, string, string) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(privateKey, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(privateKey, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var authorizedKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshKey)))
+
+	var dir = t.TempDir()
+	var privateKeyFile = filepath.Join(dir, "id_ed25519")
+	var publicKeyFile = privateKeyFile + ".pub"
+
+	if err := os.WriteFile(privateKeyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicKeyFile, []byte(authorizedKey+" team@laptop\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return privateKeyFile, publicKeyFile, authorizedKey
+}
+
+// 250 secrets, spread over 3 pages of 100 secrets
+func testSecrets() []dsecret.Response {
+	var secrets []dsecret.Response
+	for i := 1; i <= 250; i++ {
+		secrets = append(secrets, newTestSecret(uint(i), fmt.Sprintf("key-%d", i), ""))
+	}
+
+	return secrets
+}
+
+func TestSecretDelete(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		saved []dmachine.ResponseForPrivate
+		// secret 5 must be deleted
+		wantDeleted bool
+		wantCode    int
+		wantErr     string
+	}{
+		{
+			name:        "not used by machine",
+			args:        []string{"secret", "ssh", "delete", "5", "-o", "json"},
+			saved:       []dmachine.ResponseForPrivate{{ID: 42, SecretID: 6}},
+			wantDeleted: true,
+		},
+		{
+			name:     "used by machine",
+			args:     []string{"secret", "ssh", "delete", "team-key", "-o", "json"},
+			saved:    []dmachine.ResponseForPrivate{{ID: 42, SecretID: 5}, {ID: 43, SecretID: 6}, {ID: 44, SecretID: 5}},
+			wantCode: exitValidation,
+			wantErr:  "used by machine 42, 44",
+		},
+		{
+			name:        "used by machine with --force",
+			args:        []string{"secret", "ssh", "delete", "team-key", "--force", "-o", "json"},
+			saved:       []dmachine.ResponseForPrivate{{ID: 42, SecretID: 5}},
+			wantDeleted: true,
+		},
+		{
+			name:     "not found",
+			args:     []string{"secret", "ssh", "delete", "missing-key"},
+			wantCode: exitValidation,
+			wantErr:  "Secret ssh missing-key not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeDPanel(t, tt.saved...)
+			fake.secrets = []dsecret.Response{
+				{ID: 5, Name: "team-key", Type: "ssh-key"},
+				{ID: 6, Name: "other-key", Type: "ssh-key"},
+			}
+
+			stdout, err := executeCLI(t, tt.args...)
+			if exitCode(err) != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d, error = %v", exitCode(err), tt.wantCode, err)
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want %q", err, tt.wantErr)
+			}
+
+			if got := fake.received("DELETE /api/v1/secret/ssh-key/delete/5"); got != tt.wantDeleted {
+				t.Fatalf("secret 5 deleted = %v, want %v", got, tt.wantDeleted)
+			}
+
+			if !tt.wantDeleted {
+				if stdout != "" {
+					t.Errorf("stdout = %q, want empty when not deleted", stdout)
+				}
+				return
+			}
+
+			var record secretDeleteRecord
+			if err := json.Unmarshal([]byte(stdout), &record); err != nil {
+				t.Fatalf("stdout %q is not a record: %v", stdout, err)
+			}
+			if record != (secretDeleteRecord{ID: 5, Name: "team-key", Deleted: true}) {
+				t.Errorf("record = %+v", record)
+			}
+		})
+	}
+}
+
+func TestFindSecretSSHByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     string
+		wantID  uint
+		wantErr string
+	}{
+		{name: "first page", ref: "key-1", wantID: 1},
+		{name: "last page", ref: "key-250", wantID: 250},
+		{name: "by ID", ref: "150", wantID: 150},
+		{name: "not found", ref: "key-404", wantErr: "Secret ssh key-404 not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeDPanel(t)
+			fake.secrets = testSecrets()
+
+			secret, err := findSecretSSH(context.Background(), api.NewClient(), tt.ref)
+			if tt.wantErr != "" {
+				if exitCode(err) != exitValidation || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("findSecretSSH() error = %v, want %q", err, tt.wantErr)
+				}
+				if got := fake.count("GET /api/v1/secret/ssh-key/find"); got != 3 {
+					t.Errorf("list requests = %d, want every 3 pages", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findSecretSSH() error = %v", err)
+			}
+
+			if secret.ID != tt.wantID {
+				t.Errorf("findSecretSSH() = secret %d, want %d", secret.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestSelectSecretSSH(t *testing.T) {
+	tests := []struct {
+		name       string
+		secretID   string
+		secretName string
+		// secrets in the account
+		secrets []dsecret.Response
+		wantID  uint
+		// new secret created for the account without secret
+		wantCreated bool
+		wantErr     string
+	}{
+		{name: "--secret-id", secretID: "2", secrets: testSecrets(), wantID: 2},
+		{name: "--secret-name on later page", secretName: "key-220", secrets: testSecrets(), wantID: 220},
+		{name: "--secret-id not found", secretID: "999", secrets: testSecrets(), wantErr: "Secret ssh 999 not found"},
+		{name: "--secret-name not found", secretName: "missing", secrets: testSecrets(), wantErr: "Secret ssh missing not found"},
+		{name: "invalid --secret-id", secretID: "abc", secrets: testSecrets(), wantErr: "Invalid secret ssh ID"},
+		{name: "first key of the account", secrets: testSecrets(), wantID: 1},
+		{name: "new key for account without key", wantID: 1, wantCreated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeDPanel(t)
+			fake.secrets = tt.secrets
+
+			secret, err := selectSecretSSH(context.Background(), api.NewClient(), tt.secretID, tt.secretName)
+			if tt.wantErr != "" {
+				if exitCode(err) != exitValidation || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("selectSecretSSH() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectSecretSSH() error = %v", err)
+			}
+
+			if secret.ID != tt.wantID {
+				t.Errorf("selectSecretSSH() = secret %d, want %d", secret.ID, tt.wantID)
+			}
+			if got := fake.received("POST /api/v1/secret/ssh-key/create"); got != tt.wantCreated {
+				t.Errorf("secret created = %v, want %v", got, tt.wantCreated)
+			}
+			if tt.wantCreated && secret.Data.Data()["public"] == "" {
+				t.Error("created secret has no public key")
+			}
+		})
+	}
+}
+
+func TestSecretImport(t *testing.T) {
+	privateKeyFile, publicKeyFile, authorizedKey := writeTestKeyPair(t, "")
+	protectedKeyFile, protectedPublicKeyFile, _ := writeTestKeyPair(t, "secret")
+	_, otherPublicKeyFile, _ := writeTestKeyPair(t, "")
+
+	tests := []struct {
+		name string
+		args []string
+		// public key sent to dPanel
+		wantPublicKey string
+		wantCode      int
+		wantErr       string
+	}{
+		{
+			name:          "with public key file",
+			args:          []string{"--private-key-file", privateKeyFile, "--public-key-file", publicKeyFile},
+			wantPublicKey: authorizedKey + " team@laptop",
+		},
+		{
+			name:          "public key derived from private key",
+			args:          []string{"--private-key-file", privateKeyFile},
+			wantPublicKey: authorizedKey,
+		},
+		{
+			name:     "passphrase",
+			args:     []string{"--private-key-file", protectedKeyFile, "--public-key-file", protectedPublicKeyFile},
+			wantCode: exitValidation,
+			wantErr:  "protected by passphrase",
+		},
+		{
+			name:     "mismatched public key",
+			args:     []string{"--private-key-file", privateKeyFile, "--public-key-file", otherPublicKeyFile},
+			wantCode: exitValidation,
+			wantErr:  "does not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeDPanel(t)
+
+			var args = append([]string{"secret", "ssh", "import", "--name", "team-key", "-o", "json"}, tt.args...)
+			stdout, err := executeCLI(t, args...)
+			if exitCode(err) != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d, error = %v", exitCode(err), tt.wantCode, err)
+			}
+
+			if tt.wantErr != "" {
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %q, want %q", err, tt.wantErr)
+				}
+				if fake.received("POST /api/v1/secret/ssh-key/import") {
+					t.Error("invalid key pair sent to dPanel")
+				}
+				return
+			}
+
+			var payload api.ImportSecretSSHPayload
+			if err := json.Unmarshal(fake.bodies["POST /api/v1/secret/ssh-key/import"], &payload); err != nil {
+				t.Fatal(err)
+			}
+			privateKey, _ := os.ReadFile(privateKeyFile)
+			if payload.Name != "team-key" || payload.Type != "ssh-key" || payload.PublicKey != tt.wantPublicKey || payload.PrivateKey != string(privateKey) {
+				t.Errorf("payload = %+v", payload)
+			}
+
+			var record secretRecord
+			if err := json.Unmarshal([]byte(stdout), &record); err != nil {
+				t.Fatalf("stdout %q is not a secret record: %v", stdout, err)
+			}
+			if record.Name != "team-key" || record.PublicKey != tt.wantPublicKey || !strings.HasPrefix(record.Fingerprint, "SHA256:") {
+				t.Errorf("record = %+v", record)
+			}
+		})
+	}
+}
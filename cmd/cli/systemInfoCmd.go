@@ -4,17 +4,26 @@ import (
 	"runtime"
 
 	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
 	"github.com/spf13/cobra"
 )
 
+type systemInfoRecord struct {
+	OS   string `json:"os" yaml:"os"`
+	Arch string `json:"arch" yaml:"arch"`
+}
+
 func systemInfoCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "info",
 		Short: "Prints the system info",
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			logger.Normal("Your System Information:")
-			logger.Success("OS: " + runtime.GOOS)
-			logger.Success("Arch: " + runtime.GOARCH)
+
+			return output.Print(systemInfoRecord{
+				OS:   runtime.GOOS,
+				Arch: runtime.GOARCH,
+			})
 		},
 	}
 }
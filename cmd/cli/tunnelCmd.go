@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"os"
 
 	"github.com/devetek/d-panel-cli/internal/api"
 	"github.com/devetek/d-panel-cli/internal/helper"
 	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
 	"github.com/devetek/d-panel-cli/internal/tunnel"
 	"github.com/devetek/tuman/pkg/marijan"
 	"github.com/spf13/cobra"
@@ -13,6 +18,67 @@ import (
 	"golang.org/x/mod/semver"
 )
 
+type tunnelRecord struct {
+	ID       string `json:"id" yaml:"id"`
+	Listener string `json:"listener" yaml:"listener"`
+	Service  string `json:"service" yaml:"service"`
+	State    string `json:"state" yaml:"state"`
+}
+
+func newTunnelRecords(configs []marijan.Config) []tunnelRecord {
+	var records []tunnelRecord
+	for _, config := range configs {
+		records = append(records, tunnelRecord{
+			ID:       config.ID,
+			Listener: net.JoinHostPort(config.TunnelHost, config.ListenerPort),
+			Service:  net.JoinHostPort(config.ServiceHost, config.ServicePort),
+			State:    string(config.State),
+		})
+	}
+
+	return records
+}
+
+type tunnelVersionRecord struct {
+	CurrentVersion string `json:"current_version" yaml:"current_version"`
+	LatestVersion  string `json:"latest_version" yaml:"latest_version"`
+	Upgraded       bool   `json:"upgraded" yaml:"upgraded"`
+}
+
+type tunnelListenerRecord struct {
+	ID       string `json:"id" yaml:"id"`
+	Listener string `json:"listener" yaml:"listener"`
+	Service  string `json:"service" yaml:"service"`
+	// listener in tunnel server is reachable
+	ListenerReachable bool `json:"listener_reachable" yaml:"listener_reachable"`
+	// service in this machine is reachable
+	ServiceReachable bool `json:"service_reachable" yaml:"service_reachable"`
+}
+
+func (r tunnelListenerRecord) String() string {
+	var reachable = func(ok bool) string {
+		if ok {
+			return "up"
+		}
+		return "down"
+	}
+
+	return fmt.Sprintf("%s (%s %s -> %s %s)", r.ID, r.Listener, reachable(r.ListenerReachable), r.Service, reachable(r.ServiceReachable))
+}
+
+type tunnelStatusRecord struct {
+	Unit      string                 `json:"unit" yaml:"unit"`
+	Installed bool                   `json:"installed" yaml:"installed"`
+	State     string                 `json:"state" yaml:"state"`
+	Enabled   string                 `json:"enabled" yaml:"enabled"`
+	Version   string                 `json:"version" yaml:"version"`
+	Tunnels   []tunnelListenerRecord `json:"tunnels" yaml:"tunnels"`
+}
+
+type tunnelUninstallRecord struct {
+	Removed []string `json:"removed" yaml:"removed"`
+}
+
 type TunnelCmd struct {
 	cmd       *cobra.Command
 	zapLogger *zap.Logger
@@ -21,6 +87,10 @@ type TunnelCmd struct {
 	tunnelHttpService  string
 	tunnelSshListener  string
 	tunnelSshService   string
+
+	// logs option
+	follow bool
+	lines  int
 }
 
 func NewTunnelCmd(logger *zap.Logger) *TunnelCmd {
@@ -37,6 +107,12 @@ func (m *TunnelCmd) Connect() *cobra.Command {
 	m.cmd.AddCommand(
 		m.upgrade(),
 		m.create(),
+		m.status(),
+		m.start(),
+		m.stop(),
+		m.restart(),
+		m.logs(),
+		m.uninstall(),
 	)
 
 	return m.cmd
@@ -47,24 +123,26 @@ func (m *TunnelCmd) upgrade() *cobra.Command {
 		Use:   "upgrade",
 		Short: "Upgrade marijan binary",
 		Long:  fmt.Sprintf("Upgrade marijan binary to the latest version, check latest version in %s", tunnel.BinaryBaseURL),
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			// init dPanel client
 			client := api.NewClient()
 
 			// check if session exist
-			err := client.CheckSessionExist()
+			err := requireSession(cmd.Context(), client)
 			if err != nil {
-				logger.Error("Please login to your dPanel account, use command 'dnocs auth login --email=\"email@email.com\" --password=\"password\"'")
-				return
+				return err
 			}
 
-			var tunnelCreation = tunnel.NewTunnel()
+			var tunnelCreation = tunnel.NewTunnel().SetContext(cmd.Context())
 			currentVersion := tunnelCreation.GetCurrentVersion()
 			newVersion := tunnelCreation.GetNewVersion()
 
 			if newVersion == "" {
-				logger.Error("Failed to fetch the new Marijan version. Please try again later.")
-				return
+				if cmd.Context().Err() != nil {
+					return stepError(cmd.Context(), "fetching the new Marijan version", cmd.Context().Err())
+				}
+
+				return newExitError(exitNetwork, "Failed to fetch the new Marijan version. Please try again later.")
 			}
 
 			switch semver.Compare(currentVersion, newVersion) {
@@ -77,23 +155,23 @@ func (m *TunnelCmd) upgrade() *cobra.Command {
 
 				err = tunnelCreation.Download()
 				if err != nil {
-					logger.Error(err.Error())
+					return stepError(cmd.Context(), "downloading marijan", err)
 				}
 
 				err = tunnelCreation.Extract()
 				if err != nil {
-					logger.Error(err.Error())
+					return stepError(cmd.Context(), "extracting marijan", err)
 				}
-				return
+
+				return m.printVersion(currentVersion, newVersion, true)
 			case 1:
 				logger.Success("You are running the latest version " + currentVersion)
-				return
+				return m.printVersion(currentVersion, newVersion, false)
 			case 0:
 				logger.Success("You are running the latest version " + currentVersion)
-				return
+				return m.printVersion(currentVersion, newVersion, false)
 			default:
-				logger.Success("Unknown version checker status, makesure your Marijan version is valid semver")
-				return
+				return fmt.Errorf("Unknown version checker status, makesure your Marijan version is valid semver")
 			}
 		},
 	}
@@ -106,44 +184,38 @@ func (m *TunnelCmd) create() *cobra.Command {
 		Use:   "create",
 		Short: "Open connection to tunnel",
 		Long:  `Create public access to this machine use tunnel, make it accessible from dPanel.`,
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			// init dPanel client
 			client := api.NewClient()
 
 			// check if session exist
-			err := client.CheckSessionExist()
+			err := requireSession(cmd.Context(), client)
 			if err != nil {
-				logger.Error("Please login to your dPanel account, use command 'dnocs auth login --email=\"email@email.com\" --password=\"password\"'")
-				return
+				return err
 			}
 
 			// TODO: Remove sync communication after MQTT architecture completed!
 			if m.tunnelHttpListener == "" {
-				logger.Error("Please set HTTP public listener in the tunnel")
-				return
+				return validationError("Please set HTTP public listener in the tunnel")
 			}
 
 			if m.tunnelSshListener == "" {
-				logger.Error("Please set SSH public listener in the tunnel")
-				return
+				return validationError("Please set SSH public listener in the tunnel")
 			}
 
 			if !helper.IsSudo() {
-				logger.Error("You must run this command as sudo, currenty tunnel required to running under root")
-				return
+				return permissionError("You must run this command as sudo, currenty tunnel required to running under root")
 			}
 
 			if helper.IsPortUsed(tunnel.TunnelHost, m.tunnelHttpListener) {
-				logger.Error("Port already in used in the tunnel server, choose another HTTP port or contact prakasa@devetek.com")
-				return
+				return validationError("Port already in used in the tunnel server, choose another HTTP port or contact prakasa@devetek.com")
 			}
 
 			if helper.IsPortUsed(tunnel.TunnelHost, m.tunnelSshListener) {
-				logger.Error("Port already in used in the tunnel server, choose another SSH port or contact prakasa@devetek.com")
-				return
+				return validationError("Port already in used in the tunnel server, choose another SSH port or contact prakasa@devetek.com")
 			}
 
-			var tunnelCreation = tunnel.NewTunnel().SetConfig([]marijan.Config{
+			var configs = []marijan.Config{
 				{
 					NoTCP:        false,
 					ID:           fmt.Sprintf("ssh-%s-to-%s", m.tunnelSshListener, m.tunnelSshService),
@@ -166,23 +238,30 @@ func (m *TunnelCmd) create() *cobra.Command {
 					ServicePort:  m.tunnelHttpService,
 					State:        marijan.ConfigStateActive,
 				},
-			})
+			}
+
+			var tunnelCreation = tunnel.NewTunnel().SetContext(cmd.Context()).SetConfig(configs)
 
 			err = tunnelCreation.Download()
 			if err != nil {
-				logger.Error(err.Error())
+				return stepError(cmd.Context(), "downloading marijan", err)
 			}
 
 			err = tunnelCreation.Extract()
 			if err != nil {
-				logger.Error(err.Error())
+				return stepError(cmd.Context(), "extracting marijan", err)
 			}
 
 			err = tunnelCreation.CreateService()
 			if err != nil {
-				logger.Error(err.Error())
+				if cmd.Context().Err() != nil {
+					return stepError(cmd.Context(), "creating tunnel service", err)
+				}
+
+				return partialError("Marijan installed, but creating tunnel service failed: %w", err)
 			}
 
+			return output.Print(newTunnelRecords(configs))
 		},
 	}
 
@@ -193,3 +272,194 @@ func (m *TunnelCmd) create() *cobra.Command {
 
 	return runCmd
 }
+
+func (m *TunnelCmd) status() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show tunnel service state and reachability of each tunnel",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var currentTunnel = tunnel.NewTunnel().SetContext(cmd.Context())
+
+			var record = tunnelStatusRecord{
+				Unit:      currentTunnel.ServiceName(),
+				Installed: currentTunnel.IsServiceExist(),
+				State:     currentTunnel.ServiceState(),
+				Enabled:   currentTunnel.ServiceEnabled(),
+				Version:   currentTunnel.GetCurrentVersion(),
+				Tunnels:   []tunnelListenerRecord{},
+			}
+
+			// GetConfig print error when config not exist, tunnel may not be created yet
+			if _, err := os.Stat(currentTunnel.ConfigPath()); err == nil {
+				for _, config := range currentTunnel.GetConfig() {
+					var listener = net.JoinHostPort(config.TunnelHost, config.ListenerPort)
+					var service = net.JoinHostPort(config.ServiceHost, config.ServicePort)
+
+					record.Tunnels = append(record.Tunnels, tunnelListenerRecord{
+						ID:                config.ID,
+						Listener:          listener,
+						Service:           service,
+						ListenerReachable: helper.IsPortUsed(config.TunnelHost, config.ListenerPort),
+						ServiceReachable:  helper.IsPortUsed(config.ServiceHost, config.ServicePort),
+					})
+				}
+			}
+
+			if cmd.Context().Err() != nil {
+				return stepError(cmd.Context(), "checking tunnel status", cmd.Context().Err())
+			}
+
+			if !record.Installed {
+				logger.Normal("Tunnel service is not installed, use command 'dnocs tunnel create' first")
+			}
+
+			return output.Print(record)
+		},
+	}
+
+	return runCmd
+}
+
+// systemd service of the tunnel, controlled by start, stop and restart
+type tunnelService interface {
+	IsServiceExist() bool
+	ServiceState() string
+	Start() error
+	Stop() error
+	Restart() error
+}
+
+// command to run systemctl action for the tunnel service
+func (m *TunnelCmd) serviceAction(use, short, done string, action func(currentTunnel tunnelService) error) *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !helper.IsSudo() {
+				return permissionError("You must run this command as sudo, tunnel service is running under root")
+			}
+
+			var currentTunnel tunnelService = tunnel.NewTunnel().SetContext(cmd.Context())
+			if !currentTunnel.IsServiceExist() {
+				return validationError("Tunnel service is not installed, use command 'dnocs tunnel create' first")
+			}
+
+			err := action(currentTunnel)
+			if err != nil {
+				return stepError(cmd.Context(), use+" tunnel service", err)
+			}
+
+			logger.Success(fmt.Sprintf("Tunnel service %s, current state: %s", done, currentTunnel.ServiceState()))
+
+			return nil
+		},
+	}
+
+	return runCmd
+}
+
+func (m *TunnelCmd) start() *cobra.Command {
+	return m.serviceAction("start", "Start tunnel service", "started", tunnelService.Start)
+}
+
+func (m *TunnelCmd) stop() *cobra.Command {
+	return m.serviceAction("stop", "Stop tunnel service", "stopped", tunnelService.Stop)
+}
+
+func (m *TunnelCmd) restart() *cobra.Command {
+	return m.serviceAction("restart", "Restart tunnel service", "restarted", tunnelService.Restart)
+}
+
+func (m *TunnelCmd) logs() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "logs",
+		Short: "Show logs of tunnel service",
+		Long:  `Show logs of tunnel service from journalctl, --follow keeps printing new logs until interrupted.`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if m.lines <= 0 {
+				return validationError("--lines must be greater than 0")
+			}
+
+			var currentTunnel = tunnel.NewTunnel().SetContext(cmd.Context())
+
+			err := currentTunnel.Logs(os.Stdout, m.lines, m.follow)
+			if err != nil {
+				// Ctrl+C is the normal way to stop following
+				if m.follow && errors.Is(err, context.Canceled) {
+					return nil
+				}
+
+				return stepError(cmd.Context(), "reading tunnel logs", err)
+			}
+
+			return nil
+		},
+	}
+
+	runCmd.PersistentFlags().BoolVarP(&m.follow, "follow", "f", false, "Keep printing new logs until interrupted")
+	runCmd.PersistentFlags().IntVarP(&m.lines, "lines", "n", 100, "Number of recent logs to show")
+
+	return runCmd
+}
+
+func (m *TunnelCmd) uninstall() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove tunnel service, its config and marijan binary",
+		Long: `Stop and remove tunnel service from this machine:
+
+  - systemd unit /usr/lib/systemd/system/dpanel-tunnel.service
+  - tunnel config /opt/dpanel/tunnel/config.json, and its folder when empty
+  - marijan binary /usr/local/bin/marijan
+
+Other files in these folders are kept.
+
+Machine registered with --behind-tunnel is not reachable by dPanel after that.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !helper.IsSudo() {
+				return permissionError("You must run this command as sudo, tunnel service is running under root")
+			}
+
+			var currentTunnel = tunnel.NewTunnel().SetContext(cmd.Context())
+
+			removed, err := currentTunnel.Uninstall()
+			var record = tunnelUninstallRecord{Removed: removed}
+			if record.Removed == nil {
+				record.Removed = []string{}
+			}
+			if err != nil {
+				if printErr := output.Print(record); printErr != nil {
+					return printErr
+				}
+
+				if len(removed) > 0 {
+					return partialError("Tunnel partially uninstalled: %w", err)
+				}
+
+				return stepError(cmd.Context(), "uninstalling tunnel", err)
+			}
+
+			if len(removed) == 0 {
+				logger.Normal("Tunnel is not installed, nothing to remove")
+			} else {
+				logger.Success("Tunnel uninstalled")
+			}
+
+			return output.Print(record)
+		},
+	}
+
+	return runCmd
+}
+
+func (m *TunnelCmd) printVersion(currentVersion, latestVersion string, upgraded bool) error {
+	return output.Print(tunnelVersionRecord{
+		CurrentVersion: currentVersion,
+		LatestVersion:  latestVersion,
+		Upgraded:       upgraded,
+	})
+}
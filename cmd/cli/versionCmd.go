@@ -1,18 +1,24 @@
 package main
 
 import (
-	"github.com/devetek/d-panel-cli/internal/logger"
+	"github.com/devetek/d-panel-cli/internal/output"
 	"github.com/spf13/cobra"
 )
 
 var currentVersion string = "dev"
 
+type versionRecord struct {
+	Version string `json:"version" yaml:"version"`
+}
+
 func versionCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "version",
 		Short: "Prints the version",
-		Run: func(cmd *cobra.Command, args []string) {
-			logger.Success("Version: " + currentVersion)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return output.Print(versionRecord{
+				Version: currentVersion,
+			})
 		},
 	}
 }
@@ -1,48 +1,105 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
-	"path"
+	"strconv"
+	"time"
 )
 
 var (
 	BaseURL     = "https://pawon-beta.terpusat.com"
 	FrontendURL = "https://cloud.terpusat.com"
+	// default timeout for every request to dPanel API
+	Timeout = time.Second * 30
 )
 
 type Client struct {
-	BaseURL string
+	BaseURL     string
+	FrontendURL string
+	HTTPClient  *http.Client
+	Retry       RetryPolicy
+	// active profile name
+	Profile string
+
+	sessions    sessionStore
+	sessionName string
+	tokenName   string
+	// token set by LoginToken, used before DNOCS_TOKEN and stored token
+	token string
+	// invalid configuration, returned when session used
+	configErr error
 }
 
 func NewClient() *Client {
+	var profile = defaultProfile()
+
+	// profile and session store selected by configuration file, --context flag or DNOCS_SESSION_STORE
+	cfg, err := LoadConfig()
+	var sessions sessionStore
+	if err == nil {
+		var activeProfile *Profile
+		activeProfile, err = cfg.ActiveProfile()
+		if err == nil {
+			profile = *activeProfile
+			sessions, err = newSessionStore(cfg)
+		}
+	}
+
 	// for development purpose, we can set base URL from env variable
 	apiURL := os.Getenv("DNOCS_API_BASE_URL")
 	if apiURL != "" {
-		BaseURL = apiURL
+		profile.APIBaseURL = apiURL
 	}
 
 	client := &Client{
-		BaseURL: BaseURL,
+		BaseURL:     profile.APIBaseURL,
+		FrontendURL: profile.FrontendURL,
+		HTTPClient: &http.Client{
+			Timeout: Timeout,
+		},
+		Retry:       DefaultRetryPolicy,
+		Profile:     profile.Name,
+		sessions:    sessions,
+		sessionName: profile.sessionName(),
+		tokenName:   profile.tokenName(),
 	}
+
+	if err != nil {
+		client.configErr = fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// tune retry for slow or flaky network, e.g. DNOCS_API_RETRY_MAX=6 DNOCS_API_RETRY_DELAY=2s
+	if maxAttempts, err := strconv.Atoi(os.Getenv("DNOCS_API_RETRY_MAX")); err == nil {
+		client.Retry.MaxAttempts = maxAttempts
+	}
+
+	if baseDelay, err := time.ParseDuration(os.Getenv("DNOCS_API_RETRY_DELAY")); err == nil {
+		client.Retry.BaseDelay = baseDelay
+	}
+
 	return client
 }
 
 // func check session
 func (c *Client) CheckSessionExist() error {
-	// read cookieValue from file
-	cookieValue, err := c.readCookieFromFile()
-	if err != nil {
-		return err
-	}
+	return c.CheckSessionExistContext(context.Background())
+}
 
-	// check if cookieValue is empty
-	if cookieValue == "" {
+// func check session with context
+func (c *Client) CheckSessionExistContext(ctx context.Context) error {
+	// check if token or cookieValue exist
+	if !c.HasSession() {
+		if c.configErr != nil {
+			return c.configErr
+		}
 		return fmt.Errorf("no session found")
 	}
 
-	// check if cookieValue is valid
-	profile, err := c.GetProfile()
+	// check if token or cookieValue is valid
+	profile, err := c.GetProfileContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -55,41 +112,48 @@ func (c *Client) CheckSessionExist() error {
 	return nil
 }
 
-// func to write file cookieValue to file
-func (c *Client) writeCookieToFile(cookieValue string) error {
-	// check if folder .devetek exist in home directory
-	if !checkDevetekFolderExist() {
-		// create folder .devetek in home directory
-		err := createDevetekFolder()
-		if err != nil {
-			return err
-		}
+// write session cookie to session store
+func (c *Client) writeSession(cookieValue string) error {
+	if c.configErr != nil {
+		return c.configErr
 	}
 
-	// write cookieValue to file
-	devetekDir, err := getDevetekDir()
-	if err != nil {
-		return err
+	return c.sessions.write(c.sessionName, cookieValue)
+}
+
+// read session cookie from session store
+func (c *Client) readSession() (string, error) {
+	if c.configErr != nil {
+		return "", c.configErr
+	}
+
+	return c.sessions.read(c.sessionName)
+}
+
+// check if token or session exist in local machine, without validate it to dPanel
+func (c *Client) HasSession() bool {
+	if c.UsesToken() {
+		return true
 	}
 
-	err = os.WriteFile(path.Join(devetekDir, "session"), []byte(cookieValue), 0644)
+	cookieValue, err := c.readSession()
 	if err != nil {
-		return err
+		return false
 	}
-	return nil
+
+	return cookieValue != ""
 }
 
-// func to read cookie from file
-func (c *Client) readCookieFromFile() (string, error) {
-	// read cookieValue from file
-	devetekDir, err := getDevetekDir()
-	if err != nil {
-		return "", err
+// remove session and stored token from local machine
+func (c *Client) ClearSession() error {
+	if c.configErr != nil {
+		return c.configErr
 	}
 
-	cookieValue, err := os.ReadFile(path.Join(devetekDir, "session"))
+	err := c.sessions.remove(c.tokenName)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return string(cookieValue), nil
+
+	return c.sessions.remove(c.sessionName)
 }
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+)
+
+// Config is dnocs configuration stored in ~/.devetek/config.json
+type Config struct {
+	// active profile name, empty means default profile
+	CurrentContext string    `json:"current_context,omitempty"`
+	Contexts       []Profile `json:"contexts,omitempty"`
+	// where session stored, "file" (default) or "keyring"
+	SessionStore string `json:"session_store,omitempty"`
+}
+
+func configPath() (string, error) {
+	devetekDir, err := getDevetekDir()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(devetekDir, "config.json"), nil
+}
+
+// LoadConfig read configuration file, return empty configuration when file not exist
+func LoadConfig() (*Config, error) {
+	var cfg = new(Config)
+
+	configFile, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(configFile)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, fix or remove it: %w", configFile, err)
+	}
+
+	return cfg, nil
+}
+
+// Save write configuration file, only readable by the owner
+func (cfg *Config) Save() error {
+	devetekDir, err := ensureDevetekFolder()
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path.Join(devetekDir, "config.json"), content, 0600)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// APIError is returned when dPanel answers with a non success HTTP status,
+// or with a response envelope that contains an error
+type APIError struct {
+	Method string
+	Path   string
+	// HTTP status code of the response
+	StatusCode int
+	// code from the response envelope, 0 when the body is not an envelope
+	Code int
+	// error message from the server
+	Message string
+}
+
+func (e *APIError) Error() string {
+	var msg = e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+
+	return fmt.Sprintf("%s %s: %s (status %d, code %d)", e.Method, e.Path, msg, e.StatusCode, e.Code)
+}
+
+// session cookie is missing, expired or revoked
+func (e *APIError) IsUnauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden ||
+		e.Code == http.StatusUnauthorized || e.Code == http.StatusForbidden
+}
+
+// request rejected because of the submitted data
+func (e *APIError) IsValidation() bool {
+	return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity ||
+		e.Code == http.StatusBadRequest || e.Code == http.StatusUnprocessableEntity
+}
+
+// resource does not exist
+func (e *APIError) IsNotFound() bool {
+	return e.StatusCode == http.StatusNotFound || e.Code == http.StatusNotFound
+}
+
+// dPanel failed to process the request
+func (e *APIError) IsServerError() bool {
+	return e.StatusCode >= http.StatusInternalServerError
+}
+
+// IsUnauthorized report whether err is an APIError caused by an invalid session
+func IsUnauthorized(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.IsUnauthorized()
+}
+
+// IsValidation report whether err is an APIError caused by invalid input
+func IsValidation(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.IsValidation()
+}
+
+// IsNotFound report whether err is an APIError caused by a missing resource
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.IsNotFound()
+}
+
+// IsServerError report whether err is an APIError caused by dPanel itself
+func IsServerError(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.IsServerError()
+}
@@ -30,15 +30,73 @@ func checkDevetekFolderExist() bool {
 	}
 }
 
-// create folder .devetek in home directory
+// create folder .devetek in home directory, only accessible by the owner
 func createDevetekFolder() error {
 	devetekDir, err := getDevetekDir()
 	if err != nil {
 		return err
 	}
 
-	if err := os.Mkdir(devetekDir, 0755); err != nil {
+	if err := os.MkdirAll(devetekDir, 0700); err != nil {
 		return err
 	}
 	return nil
 }
+
+// make sure folder .devetek exist, and fix permission created by older version (0755)
+func ensureDevetekFolder() (string, error) {
+	devetekDir, err := getDevetekDir()
+	if err != nil {
+		return "", err
+	}
+
+	if !checkDevetekFolderExist() {
+		return devetekDir, createDevetekFolder()
+	}
+
+	return devetekDir, fixPermission(devetekDir, 0700)
+}
+
+// remove group and other permission from file or folder
+func fixPermission(name string, perm os.FileMode) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().Perm()&0077 == 0 {
+		return nil
+	}
+
+	return os.Chmod(name, perm)
+}
+
+// write file atomically, content never partially written when process killed
+func writeFileAtomic(name string, content []byte, perm os.FileMode) error {
+	tmpFile, err := os.CreateTemp(path.Dir(name), "."+path.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if err := tmpFile.Chmod(perm); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), name)
+}
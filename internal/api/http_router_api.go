@@ -1,12 +1,10 @@
 package api
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
+	"context"
 	"net/http"
-	"strings"
-	"time"
+	"net/url"
+	"strconv"
 
 	"github.com/devetek/d-panel/pkg/drouter"
 )
@@ -18,59 +16,110 @@ type jsonResponseRouter struct {
 	Error  string                 `json:"error,omitempty"`
 }
 
+// RouterList is list of router owned by the account
+type RouterList struct {
+	Routers    []drouter.ResponseRouter `json:"routers"`
+	Pagination Pagination               `json:"pagination"`
+}
+
+type jsonResponseRouterList struct {
+	Code   int        `json:"code"`
+	Status string     `json:"status,omitempty"`
+	Data   RouterList `json:"data,omitempty"`
+	Error  any        `json:"error,omitempty"`
+}
+
+// ListRouterOptions paginate list router
+type ListRouterOptions struct {
+	Page  int
+	Limit int
+}
+
 // create new router proxy for dPanel agent
 func (c *Client) CreateRouter(payload drouter.PayloadRouter) (*jsonResponseRouter, error) {
-	// get cookie session
-	cookieValue, err := c.readCookieFromFile()
-	if err != nil {
-		return nil, err
-	}
+	return c.CreateRouterContext(context.Background(), payload)
+}
 
-	// convert payload to json
-	jsonStr, err := json.Marshal(payload)
+// create new router proxy for dPanel agent with context
+func (c *Client) CreateRouterContext(ctx context.Context, payload drouter.PayloadRouter) (*jsonResponseRouter, error) {
+	var data = new(jsonResponseRouter)
+	_, err := c.do(ctx, apiRequest{
+		method:  http.MethodPost,
+		path:    "/api/v1/router/create",
+		payload: payload,
+	}, data)
 	if err != nil {
 		return nil, err
 	}
 
-	// http post create secret ssh key
-	url := c.BaseURL + "/api/v1/router/create"
-	httpClient := &http.Client{
-		Timeout: time.Second * 30,
+	return data, nil
+}
+
+// get list router
+func (c *Client) ListRouters(opts ListRouterOptions) (*jsonResponseRouterList, error) {
+	return c.ListRoutersContext(context.Background(), opts)
+}
+
+// get list router with context
+func (c *Client) ListRoutersContext(ctx context.Context, opts ListRouterOptions) (*jsonResponseRouterList, error) {
+	query := url.Values{}
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
 	}
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonStr)))
-	if err != nil {
-		return nil, err
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
 	}
 
-	// set cookie to request header, with cookie name dcloud_sid
-	req.Header.Set("Cookie", "dcloud_sid="+cookieValue)
+	var path = "/api/v1/router/find"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
 
-	// do request
-	resp, err := httpClient.Do(req)
+	var data = new(jsonResponseRouterList)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodGet,
+		path:   path,
+	}, data)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// read response header
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
+	return data, nil
+}
+
+// get router detail
+func (c *Client) GetRouter(routerID uint64) (*jsonResponseRouter, error) {
+	return c.GetRouterContext(context.Background(), routerID)
+}
 
-	// read response body with json decoder
+// get router detail with context
+func (c *Client) GetRouterContext(ctx context.Context, routerID uint64) (*jsonResponseRouter, error) {
 	var data = new(jsonResponseRouter)
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(data)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodGet,
+		path:   "/api/v1/router/detail/" + strconv.FormatUint(routerID, 10),
+	}, data)
 	if err != nil {
 		return nil, err
 	}
 
-	if data.Code != 200 {
-		return nil, fmt.Errorf("data return %d, with error %s", data.Code, data.Error)
-	}
+	return data, nil
+}
 
-	if data.Error != "" {
-		return nil, errors.New(data.Error)
+// delete router
+func (c *Client) DeleteRouter(routerID uint64) (*jsonResponseDelete, error) {
+	return c.DeleteRouterContext(context.Background(), routerID)
+}
+
+// delete router with context
+func (c *Client) DeleteRouterContext(ctx context.Context, routerID uint64) (*jsonResponseDelete, error) {
+	var data = new(jsonResponseDelete)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodDelete,
+		path:   "/api/v1/router/delete/" + strconv.FormatUint(routerID, 10),
+	}, data)
+	if err != nil {
+		return nil, err
 	}
 
 	return data, nil
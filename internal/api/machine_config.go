@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/devetek/d-panel/pkg/dmachine"
+)
+
+// get path of machine.json, registration record of this machine
+func getMachineConfigPath() (string, error) {
+	devetekDir, err := getDevetekDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(devetekDir, "machine.json"), nil
+}
+
+// ReadMachine read server registered from this machine, return nil when this machine is not registered
+func ReadMachine() (*dmachine.ResponseForPrivate, error) {
+	machineConfig, err := getMachineConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	machineContent, err := os.ReadFile(machineConfig)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var machine *dmachine.ResponseForPrivate
+	err = json.Unmarshal(machineContent, &machine)
+	if err != nil {
+		return nil, err
+	}
+
+	if machine == nil || machine.ID == 0 {
+		return nil, nil
+	}
+
+	return machine, nil
+}
+
+// SaveMachine write server registered from this machine to ~/.devetek/machine.json, only readable by the owner
+func SaveMachine(machine dmachine.ResponseForPrivate) error {
+	devetekDir, err := ensureDevetekFolder()
+	if err != nil {
+		return err
+	}
+
+	machineContent, err := json.MarshalIndent(machine, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(devetekDir, "machine.json"), machineContent, 0600)
+}
+
+// RemoveMachine remove registration record of this machine, not an error when the record not exist
+func RemoveMachine() error {
+	machineConfig, err := getMachineConfigPath()
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(machineConfig)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
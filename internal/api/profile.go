@@ -0,0 +1,191 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const DefaultProfile = "default"
+
+// profile selected by global --context flag, override current context in configuration
+var selectedProfile string
+
+var profileNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// Profile is a named context with its own dPanel environment and session
+type Profile struct {
+	Name        string `json:"name"`
+	APIBaseURL  string `json:"api_base_url"`
+	FrontendURL string `json:"frontend_url,omitempty"`
+	// empty tunnel host and port use tunnel package default
+	TunnelHost string `json:"tunnel_host,omitempty"`
+	TunnelPort string `json:"tunnel_port,omitempty"`
+	// ID or name of machine serving the tunnel host, empty to find it by tunnel host
+	GatewayMachine string `json:"gateway_machine,omitempty"`
+}
+
+// session name in session store, default profile keep the old session location
+func (p Profile) sessionName() string {
+	if p.Name == DefaultProfile {
+		return "session"
+	}
+
+	return "session-" + p.Name
+}
+
+// token name in session store
+func (p Profile) tokenName() string {
+	if p.Name == DefaultProfile {
+		return "token"
+	}
+
+	return "token-" + p.Name
+}
+
+func defaultProfile() Profile {
+	return Profile{
+		Name:        DefaultProfile,
+		APIBaseURL:  BaseURL,
+		FrontendURL: FrontendURL,
+	}
+}
+
+// SelectProfile override active profile for current process, empty name use current context
+func SelectProfile(name string) {
+	selectedProfile = name
+}
+
+// CurrentProfile load configuration and return active profile
+func CurrentProfile() (*Profile, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.ActiveProfile()
+}
+
+// active profile name, from --context flag, then current context in configuration
+func (cfg *Config) ActiveProfileName() string {
+	if selectedProfile != "" {
+		return selectedProfile
+	}
+
+	if cfg.CurrentContext != "" {
+		return cfg.CurrentContext
+	}
+
+	return DefaultProfile
+}
+
+func (cfg *Config) ActiveProfile() (*Profile, error) {
+	return cfg.Profile(cfg.ActiveProfileName())
+}
+
+// Profile find profile by name, default profile always exist
+func (cfg *Config) Profile(name string) (*Profile, error) {
+	for _, profile := range cfg.Contexts {
+		if profile.Name == name {
+			if profile.FrontendURL == "" {
+				profile.FrontendURL = FrontendURL
+			}
+			return &profile, nil
+		}
+	}
+
+	if name == DefaultProfile {
+		profile := defaultProfile()
+		return &profile, nil
+	}
+
+	return nil, fmt.Errorf("context %q not found", name)
+}
+
+// Profiles return all profile, include the built-in default profile
+func (cfg *Config) Profiles() []Profile {
+	var profiles []Profile
+	var hasDefault bool
+
+	for _, profile := range cfg.Contexts {
+		if profile.Name == DefaultProfile {
+			hasDefault = true
+		}
+		profiles = append(profiles, profile)
+	}
+
+	if !hasDefault {
+		profiles = append([]Profile{defaultProfile()}, profiles...)
+	}
+
+	return profiles
+}
+
+func (cfg *Config) AddProfile(profile Profile) error {
+	if !profileNamePattern.MatchString(profile.Name) {
+		return fmt.Errorf("invalid context name %q, use letters, numbers, dash or underscore", profile.Name)
+	}
+
+	if profile.Name == DefaultProfile {
+		return fmt.Errorf("context name %q is reserved for the built-in context", profile.Name)
+	}
+
+	if profile.APIBaseURL == "" {
+		return fmt.Errorf("API base URL of context %q is required", profile.Name)
+	}
+
+	for _, existing := range cfg.Contexts {
+		if existing.Name == profile.Name {
+			return fmt.Errorf("context %q already exist", profile.Name)
+		}
+	}
+
+	cfg.Contexts = append(cfg.Contexts, profile)
+
+	return nil
+}
+
+func (cfg *Config) UseProfile(name string) error {
+	if _, err := cfg.Profile(name); err != nil {
+		return err
+	}
+
+	cfg.CurrentContext = name
+
+	return nil
+}
+
+// DeleteProfile remove profile and its session, active profile switched back to default
+func (cfg *Config) DeleteProfile(name string) error {
+	if name == DefaultProfile {
+		return fmt.Errorf("context %q can not be deleted", name)
+	}
+
+	profile, err := cfg.Profile(name)
+	if err != nil {
+		return err
+	}
+
+	var contexts []Profile
+	for _, existing := range cfg.Contexts {
+		if existing.Name != name {
+			contexts = append(contexts, existing)
+		}
+	}
+	cfg.Contexts = contexts
+
+	if cfg.CurrentContext == name {
+		cfg.CurrentContext = ""
+	}
+
+	store, err := newSessionStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	err = store.remove(profile.tokenName())
+	if err != nil {
+		return err
+	}
+
+	return store.remove(profile.sessionName())
+}
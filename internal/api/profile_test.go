@@ -0,0 +1,60 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		wantErr string
+	}{
+		{name: "valid", profile: Profile{Name: "dev", APIBaseURL: "http://localhost:3000"}},
+		{name: "reserved default", profile: Profile{Name: DefaultProfile, APIBaseURL: "http://localhost:3000"}, wantErr: "reserved"},
+		{name: "invalid name", profile: Profile{Name: "../dev", APIBaseURL: "http://localhost:3000"}, wantErr: "invalid context name"},
+		{name: "missing API URL", profile: Profile{Name: "dev"}, wantErr: "API base URL"},
+		{name: "duplicate", profile: Profile{Name: "beta", APIBaseURL: "http://localhost:3000"}, wantErr: "already exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Contexts: []Profile{{Name: "beta", APIBaseURL: "https://beta.example.com"}}}
+
+			err := cfg.AddProfile(tt.profile)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("AddProfile() error = %v", err)
+				}
+				if _, err := cfg.Profile(tt.profile.Name); err != nil {
+					t.Errorf("Profile(%q) error = %v after AddProfile", tt.profile.Name, err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("AddProfile() error = %v, want %q", err, tt.wantErr)
+			}
+			if len(cfg.Contexts) != 1 {
+				t.Errorf("rejected context added, contexts = %v", cfg.Contexts)
+			}
+		})
+	}
+}
+
+func TestDefaultProfileAlwaysExist(t *testing.T) {
+	cfg := &Config{}
+
+	profile, err := cfg.Profile(DefaultProfile)
+	if err != nil {
+		t.Fatalf("Profile(default) error = %v", err)
+	}
+	if profile.APIBaseURL != BaseURL {
+		t.Errorf("APIBaseURL = %q, want %q", profile.APIBaseURL, BaseURL)
+	}
+
+	if err := cfg.DeleteProfile(DefaultProfile); err == nil {
+		t.Error("DeleteProfile(default), want error")
+	}
+}
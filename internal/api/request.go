@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// envelope is the common shape of every dPanel API response
+type envelope struct {
+	Code   int    `json:"code"`
+	Status string `json:"status,omitempty"`
+	Error  any    `json:"error,omitempty"`
+}
+
+// message return error message from the envelope, error can be string or object
+func (e envelope) message() string {
+	switch value := e.Error.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case map[string]any:
+		if msg, ok := value["message"].(string); ok {
+			return msg
+		}
+	}
+
+	jsonByte, err := json.Marshal(e.Error)
+	if err != nil {
+		return fmt.Sprintf("%v", e.Error)
+	}
+
+	return string(jsonByte)
+}
+
+type apiRequest struct {
+	method string
+	path   string
+	// payload encoded as JSON body, nil for request without body
+	payload any
+	// skip token and session cookie, used by login
+	anonymous bool
+}
+
+// request without side effect when sent twice, safe to retry after network error
+func (r apiRequest) idempotent() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// do send request to dPanel API, and decode the response envelope into result.
+// Response body is already consumed when the response returned, only header and cookies can be used.
+// Request is aborted when ctx is canceled or its deadline exceeded.
+func (c *Client) do(ctx context.Context, r apiRequest, result any) (*http.Response, error) {
+	var body []byte
+	if r.payload != nil {
+		jsonByte, err := json.Marshal(r.payload)
+		if err != nil {
+			return nil, err
+		}
+		body = jsonByte
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, respBody, err := c.send(ctx, r, body)
+
+		// retry when allowed, and the error is temporary
+		if attempt < c.Retry.MaxAttempts && ctx.Err() == nil {
+			if wait, ok := c.Retry.shouldRetry(r, attempt, resp, err); ok {
+				if sleepErr := sleepContext(ctx, wait); sleepErr != nil {
+					return nil, sleepErr
+				}
+				continue
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return resp, decodeResponse(r, resp, respBody, result)
+	}
+}
+
+// send one attempt of the request, and read the whole response body
+func (c *Client) send(ctx context.Context, r apiRequest, body []byte) (*http.Response, []byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, c.BaseURL+r.path, reqBody)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if !r.anonymous {
+		err = c.authorize(req)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp, respBody, nil
+}
+
+// decode the response envelope into result, non success response returned as *APIError
+func decodeResponse(r apiRequest, resp *http.Response, respBody []byte, result any) error {
+	// body can be empty or not a JSON, e.g. from reverse proxy
+	var env envelope
+	envErr := json.Unmarshal(respBody, &env)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{
+			Method:     r.method,
+			Path:       r.path,
+			StatusCode: resp.StatusCode,
+			Code:       env.Code,
+			Message:    env.message(),
+		}
+	}
+
+	if envErr != nil {
+		return fmt.Errorf("%s %s: invalid response body: %w", r.method, r.path, envErr)
+	}
+
+	if (env.Code != 0 && env.Code != http.StatusOK) || env.message() != "" {
+		return &APIError{
+			Method:     r.method,
+			Path:       r.path,
+			StatusCode: resp.StatusCode,
+			Code:       env.Code,
+			Message:    env.message(),
+		}
+	}
+
+	if result != nil {
+		err := json.Unmarshal(respBody, result)
+		if err != nil {
+			return fmt.Errorf("%s %s: invalid response body: %w", r.method, r.path, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy control how failed request to dPanel API retried
+type RetryPolicy struct {
+	// total attempts including the first request, 1 or less disable retry
+	MaxAttempts int
+	// delay before the first retry, doubled on every next retry
+	BaseDelay time.Duration
+	// upper limit of delay between retries, except delay requested by Retry-After header
+	MaxDelay time.Duration
+	// upper limit of delay requested by Retry-After header, longer delay is shortened to it
+	MaxRetryAfter time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Millisecond * 500,
+	MaxDelay:    time.Second * 10,
+	// Retry-After from hostile or buggy server must not stall the CLI
+	MaxRetryAfter: time.Second * 30,
+}
+
+// shouldRetry return wait duration before the next attempt, when the failed attempt can be retried.
+// Idempotent request retried after network error or 429/502/503/504 response,
+// non idempotent request (e.g. POST /server/create) only retried after 429 because the server reject it before processing.
+func (p RetryPolicy) shouldRetry(r apiRequest, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if err != nil {
+		// resp is nil, request may or may not reach dPanel
+		if !r.idempotent() {
+			return 0, false
+		}
+
+		return p.backoff(attempt), true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if !r.idempotent() {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+		if p.MaxRetryAfter > 0 && wait > p.MaxRetryAfter {
+			wait = p.MaxRetryAfter
+		}
+		return wait, true
+	}
+
+	return p.backoff(attempt), true
+}
+
+// exponential backoff with jitter, result between half and full of the exponential delay
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// parse Retry-After header, value can be delay in seconds or HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// sleep until duration passed, or context canceled
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	var policy = RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		attempt int
+		// exponential delay before jitter, result must be between half and full of it
+		want time.Duration
+	}{
+		{attempt: 1, want: 100 * time.Millisecond},
+		{attempt: 2, want: 200 * time.Millisecond},
+		{attempt: 3, want: 400 * time.Millisecond},
+		{attempt: 4, want: 800 * time.Millisecond},
+		{attempt: 5, want: time.Second},
+		{attempt: 100, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			got := policy.backoff(tt.attempt)
+			if got < tt.want/2 || got > tt.want {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, got, tt.want/2, tt.want)
+			}
+		}
+	}
+
+	if got := (RetryPolicy{}).backoff(3); got != 0 {
+		t.Errorf("backoff without base delay = %s, want 0", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "empty", value: ""},
+		{name: "seconds", value: "3", want: 3 * time.Second, wantOK: true},
+		{name: "zero", value: "0", want: 0, wantOK: true},
+		{name: "negative", value: "-1"},
+		{name: "invalid", value: "soon"},
+		{name: "past date", value: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), want: 0, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.value)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	// HTTP date is rounded to second
+	got, ok := retryAfter(time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat))
+	if !ok || got < 8*time.Second || got > 10*time.Second {
+		t.Errorf("retryAfter(date in 10s) = %s, %v, want about 10s", got, ok)
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	var policy = RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxRetryAfter: time.Minute}
+	var get = apiRequest{method: http.MethodGet, path: "/api/v1/server/find"}
+	var post = apiRequest{method: http.MethodPost, path: "/api/v1/server/create"}
+
+	tests := []struct {
+		name       string
+		request    apiRequest
+		status     int
+		retryAfter string
+		err        error
+		wantRetry  bool
+		wantWait   time.Duration
+	}{
+		{name: "GET network error", request: get, err: errors.New("connection reset"), wantRetry: true},
+		{name: "POST network error", request: post, err: errors.New("connection reset")},
+		{name: "GET 429", request: get, status: http.StatusTooManyRequests, wantRetry: true},
+		{name: "POST 429", request: post, status: http.StatusTooManyRequests, wantRetry: true},
+		{name: "GET 502", request: get, status: http.StatusBadGateway, wantRetry: true},
+		{name: "GET 503", request: get, status: http.StatusServiceUnavailable, wantRetry: true},
+		{name: "GET 504", request: get, status: http.StatusGatewayTimeout, wantRetry: true},
+		{name: "POST 502", request: post, status: http.StatusBadGateway},
+		{name: "POST 503", request: post, status: http.StatusServiceUnavailable},
+		{name: "GET 500", request: get, status: http.StatusInternalServerError},
+		{name: "GET 404", request: get, status: http.StatusNotFound},
+		{name: "GET 200", request: get, status: http.StatusOK},
+		{name: "Retry-After used", request: post, status: http.StatusTooManyRequests, retryAfter: "7", wantRetry: true, wantWait: 7 * time.Second},
+		{name: "Retry-After clamped", request: get, status: http.StatusServiceUnavailable, retryAfter: "86400", wantRetry: true, wantWait: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status, Header: http.Header{}}
+				if tt.retryAfter != "" {
+					resp.Header.Set("Retry-After", tt.retryAfter)
+				}
+			}
+
+			wait, retry := policy.shouldRetry(tt.request, 1, resp, tt.err)
+			if retry != tt.wantRetry {
+				t.Fatalf("shouldRetry() retry = %v, want %v", retry, tt.wantRetry)
+			}
+			if tt.wantWait != 0 && wait != tt.wantWait {
+				t.Errorf("shouldRetry() wait = %s, want %s", wait, tt.wantWait)
+			}
+			if retry && wait > policy.MaxRetryAfter {
+				t.Errorf("shouldRetry() wait = %s, more than MaxRetryAfter %s", wait, policy.MaxRetryAfter)
+			}
+		})
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		status     int
+		retryAfter string
+		// attempts received by the server, with MaxAttempts 3
+		wantAttempts int32
+	}{
+		{name: "GET retried until success", method: http.MethodGet, status: http.StatusServiceUnavailable, wantAttempts: 3},
+		{name: "POST not retried on 503", method: http.MethodPost, status: http.StatusServiceUnavailable, wantAttempts: 1},
+		{name: "POST retried on 429", method: http.MethodPost, status: http.StatusTooManyRequests, wantAttempts: 3},
+		{name: "long Retry-After clamped", method: http.MethodGet, status: http.StatusTooManyRequests, retryAfter: "3600", wantAttempts: 3},
+		{name: "not retried on 500", method: http.MethodGet, status: http.StatusInternalServerError, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+
+				// the third attempt succeed
+				if attempts.Add(1) < 3 {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(`{"error":"try again"}`))
+					return
+				}
+
+				_, _ = w.Write([]byte(`{"code":200,"status":"OK"}`))
+			}))
+			defer server.Close()
+
+			client := newTestClient(t, server.URL)
+			client.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxRetryAfter: 10 * time.Millisecond}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err := client.do(ctx, apiRequest{method: tt.method, path: "/api/v1/test", payload: map[string]string{"a": "b"}, anonymous: true}, nil)
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+
+			if tt.wantAttempts == 3 && err != nil {
+				t.Errorf("do() error = %v after retry", err)
+			}
+			if tt.wantAttempts == 1 && err == nil {
+				t.Error("do() error = nil, want error of the first attempt")
+			}
+		})
+	}
+}
@@ -1,10 +1,11 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
-	"strings"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/devetek/d-panel/pkg/dsecret"
@@ -24,57 +25,44 @@ type jsonResponseSecretSSH struct {
 	Error  any              `json:"error,omitempty"`
 }
 
-// create ssh key
-func (c *Client) CreateSecretSSH() (*jsonResponseSecretSSH, error) {
-	// get cookie session
-	cookieValue, err := c.readCookieFromFile()
-	if err != nil {
-		return nil, err
-	}
+// default size of RSA key generated by dPanel
+const DefaultSSHKeySize = 4096
 
-	// set payload
-	var payload = dsecret.Payload{
-		KeySize:   4096,
-		Name:      fmt.Sprintf("cli-ssh-key-%s", time.Now().Format("20060102150405")), // add date time
-		Type:      "ssh-key",
-		KeyPrefix: "",
-	}
+// CreateSecretSSHOptions set name and size of new ssh key, zero value use default
+type CreateSecretSSHOptions struct {
+	Name    string
+	KeySize int
+}
 
-	// convert payload to json
-	jsonStr, err := json.Marshal(payload)
-	if err != nil {
-		return nil, err
-	}
+// create ssh key
+func (c *Client) CreateSecretSSH(opts CreateSecretSSHOptions) (*jsonResponseSecretSSH, error) {
+	return c.CreateSecretSSHContext(context.Background(), opts)
+}
 
-	// http post create secret ssh key
-	url := c.BaseURL + "/api/v1/secret/ssh-key/create"
-	httpClient := &http.Client{
-		Timeout: time.Second * 30,
-	}
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonStr)))
-	if err != nil {
-		return nil, err
+// create ssh key with context
+func (c *Client) CreateSecretSSHContext(ctx context.Context, opts CreateSecretSSHOptions) (*jsonResponseSecretSSH, error) {
+	if opts.KeySize == 0 {
+		opts.KeySize = DefaultSSHKeySize
 	}
 
-	// set cookie to request header, with cookie name dcloud_sid
-	req.Header.Set("Cookie", "dcloud_sid="+cookieValue)
-
-	// do request
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	if opts.Name == "" {
+		opts.Name = fmt.Sprintf("cli-ssh-key-%s", time.Now().Format("20060102150405")) // add date time
 	}
-	defer resp.Body.Close()
 
-	// read response header
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	// set payload
+	var payload = dsecret.Payload{
+		KeySize:   opts.KeySize,
+		Name:      opts.Name,
+		Type:      "ssh-key",
+		KeyPrefix: "",
 	}
 
-	// read response body with json decoder
 	var data = new(jsonResponseSecretSSH)
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(data)
+	_, err := c.do(ctx, apiRequest{
+		method:  http.MethodPost,
+		path:    "/api/v1/secret/ssh-key/create",
+		payload: payload,
+	}, data)
 	if err != nil {
 		return nil, err
 	}
@@ -84,38 +72,40 @@ func (c *Client) CreateSecretSSH() (*jsonResponseSecretSSH, error) {
 
 // get list secret ssh
 func (c *Client) GetListSecretSSH() (*jsonResponseSecretSSHList, error) {
-	cookieValue, err := c.readCookieFromFile()
-	if err != nil {
-		return nil, err
-	}
+	return c.GetListSecretSSHContext(context.Background())
+}
 
-	url := c.BaseURL + "/api/v1/secret/ssh-key/find"
-	httpClient := &http.Client{
-		Timeout: time.Second * 5,
-	}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+// get list secret ssh with context, the first page by dPanel default
+func (c *Client) GetListSecretSSHContext(ctx context.Context) (*jsonResponseSecretSSHList, error) {
+	return c.ListSecretSSHContext(ctx, ListSecretSSHOptions{})
+}
 
-	// set cookie to request header, with cookie name dcloud_sid
-	req.Header.Set("Cookie", "dcloud_sid="+cookieValue)
+// ListSecretSSHOptions paginate list secret ssh
+type ListSecretSSHOptions struct {
+	Page  int
+	Limit int
+}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
+// get page of list secret ssh with context
+func (c *Client) ListSecretSSHContext(ctx context.Context, opts ListSecretSSHOptions) (*jsonResponseSecretSSHList, error) {
+	query := url.Values{}
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
 	}
-	defer resp.Body.Close()
 
-	// read response header
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	var path = "/api/v1/secret/ssh-key/find"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
 	}
 
-	// read response body with json decoder
 	var data = new(jsonResponseSecretSSHList)
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(data)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodGet,
+		path:   path,
+	}, data)
 	if err != nil {
 		return nil, err
 	}
@@ -125,39 +115,67 @@ func (c *Client) GetListSecretSSH() (*jsonResponseSecretSSHList, error) {
 
 // get secret ssh by id
 func (c *Client) GetSecretSSHByID(secretID string) (*jsonResponseSecretSSH, error) {
-	cookieValue, err := c.readCookieFromFile()
-	if err != nil {
-		return nil, err
-	}
+	return c.GetSecretSSHByIDContext(context.Background(), secretID)
+}
 
-	// get secret ssh by id
-	url := fmt.Sprintf("%s/api/v1/secret/ssh-key/detail/%s", c.BaseURL, secretID)
-	httpClient := &http.Client{
-		Timeout: time.Second * 5,
-	}
-	req, err := http.NewRequest("GET", url, nil)
+// get secret ssh by id with context
+func (c *Client) GetSecretSSHByIDContext(ctx context.Context, secretID string) (*jsonResponseSecretSSH, error) {
+	var data = new(jsonResponseSecretSSH)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodGet,
+		path:   "/api/v1/secret/ssh-key/detail/" + secretID,
+	}, data)
 	if err != nil {
 		return nil, err
 	}
 
-	// set cookie to request header, with cookie name dcloud_sid
-	req.Header.Set("Cookie", "dcloud_sid="+cookieValue)
+	return data, nil
+}
 
-	resp, err := httpClient.Do(req)
+// delete secret ssh
+func (c *Client) DeleteSecretSSH(secretID string) (*jsonResponseDelete, error) {
+	return c.DeleteSecretSSHContext(context.Background(), secretID)
+}
+
+// delete secret ssh with context
+func (c *Client) DeleteSecretSSHContext(ctx context.Context, secretID string) (*jsonResponseDelete, error) {
+	var data = new(jsonResponseDelete)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodDelete,
+		path:   "/api/v1/secret/ssh-key/delete/" + secretID,
+	}, data)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// read response header
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	return data, nil
+}
+
+// ImportSecretSSHPayload upload existing ssh key pair to dPanel
+type ImportSecretSSHPayload struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+// import ssh key
+func (c *Client) ImportSecretSSH(payload ImportSecretSSHPayload) (*jsonResponseSecretSSH, error) {
+	return c.ImportSecretSSHContext(context.Background(), payload)
+}
+
+// import ssh key with context
+func (c *Client) ImportSecretSSHContext(ctx context.Context, payload ImportSecretSSHPayload) (*jsonResponseSecretSSH, error) {
+	if payload.Type == "" {
+		payload.Type = "ssh-key"
 	}
 
-	// read response body with json decoder
 	var data = new(jsonResponseSecretSSH)
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(data)
+	_, err := c.do(ctx, apiRequest{
+		method:  http.MethodPost,
+		path:    "/api/v1/secret/ssh-key/import",
+		payload: payload,
+	}, data)
 	if err != nil {
 		return nil, err
 	}
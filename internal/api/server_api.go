@@ -1,14 +1,12 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/devetek/d-panel/pkg/dmachine"
 )
@@ -20,6 +18,42 @@ type jsonResponseServer struct {
 	Error  string                      `json:"error,omitempty"`
 }
 
+// Pagination of list response
+type Pagination struct {
+	Page      int `json:"page"`
+	Limit     int `json:"limit"`
+	TotalItem int `json:"total_item"`
+	TotalPage int `json:"total_page"`
+}
+
+// ServerList is list of server owned by the account
+type ServerList struct {
+	Servers    []dmachine.ResponseForPrivate `json:"servers"`
+	Pagination Pagination                    `json:"pagination"`
+}
+
+type jsonResponseServerList struct {
+	Code   int        `json:"code"`
+	Status string     `json:"status,omitempty"`
+	Data   ServerList `json:"data,omitempty"`
+	Error  any        `json:"error,omitempty"`
+}
+
+// ListServerOptions filter and paginate list server
+type ListServerOptions struct {
+	Page     int
+	Limit    int
+	Status   string
+	Provider string
+}
+
+type jsonResponseDelete struct {
+	Code   int    `json:"code"`
+	Status string `json:"status,omitempty"`
+	Data   any    `json:"data,omitempty"`
+	Error  any    `json:"error,omitempty"`
+}
+
 type jsonResponseSetup struct {
 	Code   int    `json:"code"`
 	Status string `json:"status,omitempty"`
@@ -28,171 +62,232 @@ type jsonResponseSetup struct {
 }
 
 func (c *Client) IsRegistered() bool {
-	devetekDir, err := getDevetekDir()
-	if err != nil {
-		return false
-	}
-
-	machineConfig := filepath.Join(devetekDir, "machine.json")
+	return c.IsRegisteredContext(context.Background())
+}
 
-	machineContent, err := os.ReadFile(machineConfig)
-	if err != nil {
+// check registered machine with context
+func (c *Client) IsRegisteredContext(ctx context.Context) bool {
+	machine, err := ReadMachine()
+	if err != nil || machine == nil {
 		return false
 	}
 
-	var machine *dmachine.ResponseForPrivate
-	err = json.Unmarshal(machineContent, &machine)
+	// fetch to validate to dPanel
+	server, err := c.GetServerContext(ctx, machine.GetUint64ID())
 	if err != nil {
 		return false
 	}
 
-	if machine == nil {
+	if server.Data.ID == 0 {
 		return false
 	}
 
-	if machine.ID == 0 {
-		return false
-	}
+	return true
+}
 
-	// read session from file
-	cookieValue, err := c.readCookieFromFile()
-	if err != nil {
-		return false
-	}
+// register new server
+func (c *Client) RegisterServer(newServer dmachine.Payload) (*jsonResponseServer, error) {
+	return c.RegisterServerContext(context.Background(), newServer)
+}
 
-	// fetch to validate to dPanel
-	url := c.BaseURL + "/api/v1/server/detail/" + strconv.FormatInt(int64(machine.GetUint64ID()), 10)
-	httpClient := &http.Client{
-		Timeout: time.Second * 5,
-	}
-	req, err := http.NewRequest("GET", url, nil)
+// register new server with context
+func (c *Client) RegisterServerContext(ctx context.Context, newServer dmachine.Payload) (*jsonResponseServer, error) {
+	var servers = new(jsonResponseServer)
+	_, err := c.do(ctx, apiRequest{
+		method:  http.MethodPost,
+		path:    "/api/v1/server/create",
+		payload: newServer,
+	}, servers)
 	if err != nil {
-		return false
+		return nil, err
 	}
 
-	// set cookie to request header, with cookie name dcloud_sid
-	req.Header.Set("Cookie", "dcloud_sid="+cookieValue)
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
+	return servers, nil
+}
 
-	// read response header
-	if resp.StatusCode != http.StatusOK {
-		return false
-	}
+// update server, e.g. change SSH key used by dPanel
+func (c *Client) UpdateServer(serverID uint64, payload dmachine.Payload) (*jsonResponseServer, error) {
+	return c.UpdateServerContext(context.Background(), serverID, payload)
+}
 
-	// read response body with json decoder
+// update server with context
+func (c *Client) UpdateServerContext(ctx context.Context, serverID uint64, payload dmachine.Payload) (*jsonResponseServer, error) {
 	var server = new(jsonResponseServer)
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(server)
+	_, err := c.do(ctx, apiRequest{
+		method:  http.MethodPut,
+		path:    "/api/v1/server/update/" + strconv.FormatUint(serverID, 10),
+		payload: payload,
+	}, server)
 	if err != nil {
-		return false
+		return nil, err
 	}
 
-	if server.Error != "" {
-		return false
-	}
+	return server, nil
+}
 
-	if server.Code != 200 {
-		return false
-	}
+// setup server
+func (c *Client) SetupServer(serverID int) (*jsonResponseSetup, error) {
+	return c.SetupServerContext(context.Background(), serverID)
+}
 
-	if server.Data.ID == 0 {
-		return false
+// setup server with context
+func (c *Client) SetupServerContext(ctx context.Context, serverID int) (*jsonResponseSetup, error) {
+	var setup = new(jsonResponseSetup)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodPost,
+		path:   fmt.Sprintf("/api/v1/server/setup/%d", serverID),
+	}, setup)
+	if err != nil {
+		return nil, err
 	}
 
-	return true
+	return setup, nil
 }
 
-// register new server
-func (c *Client) RegisterServer(newServer dmachine.Payload) (*jsonResponseServer, error) {
+// get server detail
+func (c *Client) GetServer(serverID uint64) (*jsonResponseServer, error) {
+	return c.GetServerContext(context.Background(), serverID)
+}
 
-	// read session from file
-	cookieValue, err := c.readCookieFromFile()
+// get server detail with context
+func (c *Client) GetServerContext(ctx context.Context, serverID uint64) (*jsonResponseServer, error) {
+	var server = new(jsonResponseServer)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodGet,
+		path:   "/api/v1/server/detail/" + strconv.FormatUint(serverID, 10),
+	}, server)
 	if err != nil {
 		return nil, err
 	}
 
-	jsonStr, err := json.Marshal(newServer)
-	if err != nil {
-		return nil, err
-	}
+	return server, nil
+}
 
-	url := c.BaseURL + "/api/v1/server/create"
-	httpClient := &http.Client{
-		Timeout: time.Second * 5,
-	}
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonStr)))
+// delete server from dPanel
+func (c *Client) DeleteServer(serverID uint64) (*jsonResponseDelete, error) {
+	return c.DeleteServerContext(context.Background(), serverID)
+}
+
+// delete server from dPanel with context
+func (c *Client) DeleteServerContext(ctx context.Context, serverID uint64) (*jsonResponseDelete, error) {
+	var data = new(jsonResponseDelete)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodDelete,
+		path:   "/api/v1/server/delete/" + strconv.FormatUint(serverID, 10),
+	}, data)
 	if err != nil {
 		return nil, err
 	}
 
-	// set cookie to request header, with cookie name dcloud_sid
-	req.Header.Set("Cookie", "dcloud_sid="+cookieValue)
+	return data, nil
+}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// get list server
+func (c *Client) ListServers(opts ListServerOptions) (*jsonResponseServerList, error) {
+	return c.ListServersContext(context.Background(), opts)
+}
 
-	// read response header
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+// get list server with context.
+// dPanel may ignore status and provider filter, so filtered list is built from every page
+// of the account, and paginated again here to keep page size and totals consistent.
+func (c *Client) ListServersContext(ctx context.Context, opts ListServerOptions) (*jsonResponseServerList, error) {
+	if opts.Status == "" && opts.Provider == "" {
+		return c.listServersPage(ctx, opts)
 	}
 
-	// read response body with json decoder
-	var servers = new(jsonResponseServer)
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(servers)
-	if err != nil {
-		return nil, err
+	var matched []dmachine.ResponseForPrivate
+	var servers *jsonResponseServerList
+	for page := 1; ; page++ {
+		var err error
+		servers, err = c.listServersPage(ctx, ListServerOptions{
+			Page:     page,
+			Limit:    listAllLimit,
+			Status:   opts.Status,
+			Provider: opts.Provider,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, server := range servers.Data.Servers {
+			if opts.Status != "" && !strings.EqualFold(fmt.Sprint(server.Status), opts.Status) {
+				continue
+			}
+			if opts.Provider != "" && !strings.EqualFold(server.Provider, opts.Provider) {
+				continue
+			}
+			matched = append(matched, server)
+		}
+
+		if len(servers.Data.Servers) == 0 || page >= servers.Data.Pagination.TotalPage {
+			break
+		}
 	}
 
+	servers.Data = paginateServers(matched, opts.Page, opts.Limit)
+
 	return servers, nil
 }
 
-// setup server
-func (c *Client) SetupServer(serverID int) (*jsonResponseSetup, error) {
-	// read session from file
-	cookieValue, err := c.readCookieFromFile()
-	if err != nil {
-		return nil, err
+// page size used to fetch every server of the account
+const listAllLimit = 100
+
+// one page of list server as returned by dPanel
+func (c *Client) listServersPage(ctx context.Context, opts ListServerOptions) (*jsonResponseServerList, error) {
+	query := url.Values{}
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Status != "" {
+		query.Set("status", opts.Status)
+	}
+	if opts.Provider != "" {
+		query.Set("provider", opts.Provider)
 	}
 
-	url := fmt.Sprintf("%s/api/v1/server/setup/%d", c.BaseURL, serverID)
-	httpClient := &http.Client{
-		Timeout: time.Second * 5,
+	var path = "/api/v1/server/find"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
 	}
-	req, err := http.NewRequest("POST", url, nil)
+
+	var servers = new(jsonResponseServerList)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodGet,
+		path:   path,
+	}, servers)
 	if err != nil {
 		return nil, err
 	}
 
-	// set cookie to request header, with cookie name dcloud_sid
-	req.Header.Set("Cookie", "dcloud_sid="+cookieValue)
+	return servers, nil
+}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
+// cut page from the whole list, limit 0 or less return every server in one page
+func paginateServers(servers []dmachine.ResponseForPrivate, page, limit int) ServerList {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = max(len(servers), 1)
 	}
-	defer resp.Body.Close()
 
-	// read response header
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	var list = ServerList{
+		Servers: []dmachine.ResponseForPrivate{},
+		Pagination: Pagination{
+			Page:      page,
+			Limit:     limit,
+			TotalItem: len(servers),
+			TotalPage: (len(servers) + limit - 1) / limit,
+		},
 	}
 
-	// read response body with json decoder
-	var setup = new(jsonResponseSetup)
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(setup)
-	if err != nil {
-		return nil, err
+	start := (page - 1) * limit
+	if start < len(servers) {
+		list.Servers = servers[start:min(start+limit, len(servers))]
 	}
 
-	return setup, nil
+	return list
 }
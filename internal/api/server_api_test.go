@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/devetek/d-panel/pkg/dmachine"
+)
+
+// dPanel list server which ignore status and provider filter, and only paginate
+func newServerListAPI(t *testing.T, servers []dmachine.ResponseForPrivate) (*httptest.Server, *int) {
+	t.Helper()
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 {
+			limit = 20
+		}
+
+		start := min((page-1)*limit, len(servers))
+		end := min(start+limit, len(servers))
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"code": 200,
+			"data": ServerList{
+				Servers: servers[start:end],
+				Pagination: Pagination{
+					Page:      page,
+					Limit:     limit,
+					TotalItem: len(servers),
+					TotalPage: (len(servers) + limit - 1) / limit,
+				},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestListServersFilter(t *testing.T) {
+	// 250 servers, every third one is "ready", spread over 3 pages of dPanel
+	var servers []dmachine.ResponseForPrivate
+	for i := 1; i <= 250; i++ {
+		var status = "pending"
+		if i%3 == 0 {
+			status = "ready"
+		}
+		servers = append(servers, dmachine.ResponseForPrivate{ID: uint(i), Provider: "other", Status: status})
+	}
+
+	tests := []struct {
+		name          string
+		opts          ListServerOptions
+		wantIDs       []uint
+		wantTotal     int
+		wantTotalPage int
+		wantRequests  int
+	}{
+		{
+			name:          "first page of filtered list is full",
+			opts:          ListServerOptions{Page: 1, Limit: 3, Status: "ready"},
+			wantIDs:       []uint{3, 6, 9},
+			wantTotal:     83,
+			wantTotalPage: 28,
+			wantRequests:  3,
+		},
+		{
+			name:          "page from the last dPanel page",
+			opts:          ListServerOptions{Page: 28, Limit: 3, Status: "READY"},
+			wantIDs:       []uint{246, 249},
+			wantTotal:     83,
+			wantTotalPage: 28,
+			wantRequests:  3,
+		},
+		{
+			name:          "page after the last one is empty",
+			opts:          ListServerOptions{Page: 29, Limit: 3, Status: "ready"},
+			wantTotal:     83,
+			wantTotalPage: 28,
+			wantRequests:  3,
+		},
+		{
+			name:          "no match",
+			opts:          ListServerOptions{Page: 1, Limit: 20, Provider: "aws"},
+			wantTotal:     0,
+			wantTotalPage: 0,
+			wantRequests:  3,
+		},
+		{
+			name:          "without filter use dPanel pagination",
+			opts:          ListServerOptions{Page: 2, Limit: 2},
+			wantIDs:       []uint{3, 4},
+			wantTotal:     250,
+			wantTotalPage: 125,
+			wantRequests:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newServerListAPI(t, servers)
+			client := newTestClient(t, server.URL)
+			t.Setenv("DNOCS_TOKEN", "test-token")
+
+			list, err := client.ListServersContext(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatalf("ListServersContext() error = %v", err)
+			}
+
+			var gotIDs []uint
+			for _, server := range list.Data.Servers {
+				gotIDs = append(gotIDs, server.ID)
+			}
+			if len(gotIDs) != len(tt.wantIDs) {
+				t.Fatalf("IDs = %v, want %v", gotIDs, tt.wantIDs)
+			}
+			for i := range gotIDs {
+				if gotIDs[i] != tt.wantIDs[i] {
+					t.Fatalf("IDs = %v, want %v", gotIDs, tt.wantIDs)
+				}
+			}
+
+			var pagination = list.Data.Pagination
+			if pagination.TotalItem != tt.wantTotal || pagination.TotalPage != tt.wantTotalPage {
+				t.Errorf("total = %d item, %d page, want %d item, %d page", pagination.TotalItem, pagination.TotalPage, tt.wantTotal, tt.wantTotalPage)
+			}
+			if pagination.Page != tt.opts.Page {
+				t.Errorf("page = %d, want %d", pagination.Page, tt.opts.Page)
+			}
+
+			if *requests != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", *requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestUpdateServerContext(t *testing.T) {
+	var request string
+	var payload dmachine.Payload
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r.Method + " " + r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"code":200,"data":{"id":42,"secret_id":7}}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL)
+	t.Setenv("DNOCS_TOKEN", "test-token")
+
+	var want = dmachine.Payload{Provider: "dpanel", SecretID: "7", Address: "10.0.0.42", SSHPort: "22", HTTPPort: "80", SSHUser: "devetek"}
+	updated, err := client.UpdateServerContext(context.Background(), 42, want)
+	if err != nil {
+		t.Fatalf("UpdateServerContext() error = %v", err)
+	}
+
+	if request != "PUT /api/v1/server/update/42" {
+		t.Errorf("request = %q, want PUT /api/v1/server/update/42", request)
+	}
+	if payload != want {
+		t.Errorf("payload = %+v, want %+v", payload, want)
+	}
+	if updated.Data.ID != 42 || updated.Data.SecretID != 7 {
+		t.Errorf("updated server = %+v", updated.Data)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ServerLog is one line of setup or job log of a server
+type ServerLog struct {
+	ID uint64 `json:"id"`
+	// job which write the log, e.g. setup
+	Job       string    `json:"job"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ServerLogList is list of server log, ordered from the oldest
+type ServerLogList struct {
+	Logs []ServerLog `json:"logs"`
+}
+
+type jsonResponseServerLog struct {
+	Code   int           `json:"code"`
+	Status string        `json:"status,omitempty"`
+	Data   ServerLogList `json:"data,omitempty"`
+	Error  any           `json:"error,omitempty"`
+}
+
+// get server logs
+func (c *Client) GetServerLogs(serverID uint64, afterID uint64) (*jsonResponseServerLog, error) {
+	return c.GetServerLogsContext(context.Background(), serverID, afterID)
+}
+
+// get server logs with context, only logs after afterID returned, use 0 to get all logs
+func (c *Client) GetServerLogsContext(ctx context.Context, serverID uint64, afterID uint64) (*jsonResponseServerLog, error) {
+	var path = "/api/v1/server/logs/" + strconv.FormatUint(serverID, 10)
+	if afterID > 0 {
+		path += "?" + url.Values{"after": {strconv.FormatUint(afterID, 10)}}.Encode()
+	}
+
+	var data = new(jsonResponseServerLog)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodGet,
+		path:   path,
+	}, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip logs already returned, in case dPanel ignore the filter
+	var logs []ServerLog
+	for _, log := range data.Data.Logs {
+		if log.ID > afterID {
+			logs = append(logs, log)
+		}
+	}
+	data.Data.Logs = logs
+
+	return data, nil
+}
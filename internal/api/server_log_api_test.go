@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetServerLogsContext(t *testing.T) {
+	var query string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/server/logs/42" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.RawQuery
+
+		// dPanel ignoring the after filter return logs already printed
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"code":200,"data":{"logs":[
+			{"id":4,"job":"setup","message":"old","created_at":"2026-01-02T03:04:05Z"},
+			{"id":5,"job":"setup","message":"printed","created_at":"2026-01-02T03:04:06Z"},
+			{"id":6,"job":"setup","level":"error","message":"new","created_at":"2026-01-02T10:04:07+07:00"},
+			{"id":7,"message":"without time"}
+		]}}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL)
+	t.Setenv("DNOCS_TOKEN", "test-token")
+
+	logs, err := client.GetServerLogsContext(context.Background(), 42, 5)
+	if err != nil {
+		t.Fatalf("GetServerLogsContext() error = %v", err)
+	}
+
+	if query != "after=5" {
+		t.Errorf("query = %q, want after=5", query)
+	}
+
+	if len(logs.Data.Logs) != 2 || logs.Data.Logs[0].ID != 6 || logs.Data.Logs[1].ID != 7 {
+		t.Fatalf("logs = %+v, want log 6 and 7", logs.Data.Logs)
+	}
+
+	var want = time.Date(2026, 1, 2, 3, 4, 7, 0, time.UTC)
+	if !logs.Data.Logs[0].CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %s, want %s", logs.Data.Logs[0].CreatedAt, want)
+	}
+	if !logs.Data.Logs[1].CreatedAt.IsZero() {
+		t.Errorf("CreatedAt of log without time = %s, want zero", logs.Data.Logs[1].CreatedAt)
+	}
+
+	// first request get every log
+	_, err = client.GetServerLogsContext(context.Background(), 42, 0)
+	if err != nil {
+		t.Fatalf("GetServerLogsContext() error = %v", err)
+	}
+	if query != "" {
+		t.Errorf("query without cursor = %q, want empty", query)
+	}
+}
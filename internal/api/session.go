@@ -0,0 +1,153 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+const (
+	SessionStoreFile    = "file"
+	SessionStoreKeyring = "keyring"
+)
+
+// binary to talk with Secret Service (GNOME Keyring, KWallet, KeePassXC) over D-Bus,
+// can be replaced with DNOCS_SECRET_TOOL, e.g. mock script in test
+var secretToolBin = "secret-tool"
+
+// sessionStore persist session value in local machine
+type sessionStore interface {
+	read(name string) (string, error)
+	write(name, value string) error
+	remove(name string) error
+}
+
+// select session store from DNOCS_SESSION_STORE or configuration file
+func newSessionStore(cfg *Config) (sessionStore, error) {
+	var storeType = cfg.SessionStore
+	if envStore := os.Getenv("DNOCS_SESSION_STORE"); envStore != "" {
+		storeType = envStore
+	}
+
+	switch storeType {
+	case "", SessionStoreFile:
+		return &fileSessionStore{}, nil
+	case SessionStoreKeyring:
+		bin := secretToolBin
+		if envBin := os.Getenv("DNOCS_SECRET_TOOL"); envBin != "" {
+			bin = envBin
+		}
+		return &keyringSessionStore{bin: bin, service: "dnocs"}, nil
+	}
+
+	return nil, fmt.Errorf("unknown session store %q, use %q or %q", storeType, SessionStoreFile, SessionStoreKeyring)
+}
+
+// fileSessionStore keep session in ~/.devetek/<name>, only accessible by the owner
+type fileSessionStore struct{}
+
+func (s *fileSessionStore) read(name string) (string, error) {
+	devetekDir, err := getDevetekDir()
+	if err != nil {
+		return "", err
+	}
+
+	sessionFile := path.Join(devetekDir, name)
+
+	content, err := os.ReadFile(sessionFile)
+	if err != nil {
+		return "", err
+	}
+
+	// session written by older version readable by other users
+	err = fixPermission(devetekDir, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	err = fixPermission(sessionFile, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+func (s *fileSessionStore) write(name, value string) error {
+	devetekDir, err := ensureDevetekFolder()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path.Join(devetekDir, name), []byte(value), 0600)
+}
+
+func (s *fileSessionStore) remove(name string) error {
+	devetekDir, err := getDevetekDir()
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path.Join(devetekDir, name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// keyringSessionStore keep session in Secret Service with secret-tool from libsecret
+type keyringSessionStore struct {
+	bin     string
+	service string
+}
+
+func (s *keyringSessionStore) run(stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(s.bin, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("keyring session store requires %s (libsecret-tools): %w", s.bin, err)
+	}
+	if err != nil {
+		return stdout.String(), fmt.Errorf("%s %s: %w %s", s.bin, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+func (s *keyringSessionStore) read(name string) (string, error) {
+	value, err := s.run("", "lookup", "service", s.service, "account", name)
+	if err != nil {
+		// secret-tool exit with code 1 without message when secret not found
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && value == "" {
+			return "", fmt.Errorf("%s not found in keyring: %w", name, fs.ErrNotExist)
+		}
+		return "", err
+	}
+
+	return strings.TrimRight(value, "\n"), nil
+}
+
+func (s *keyringSessionStore) write(name, value string) error {
+	_, err := s.run(value, "store", "--label", "dnocs "+name, "service", s.service, "account", name)
+
+	return err
+}
+
+func (s *keyringSessionStore) remove(name string) error {
+	_, err := s.run("", "clear", "service", s.service, "account", name)
+
+	return err
+}
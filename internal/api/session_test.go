@@ -0,0 +1,177 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mock of secret-tool, keep every secret as file in FAKE_KEYRING folder
+const fakeSecretTool = `#!/bin/sh
+action=$1
+shift
+if [ "$action" = "store" ]; then
+	# skip --label <label>
+	shift 2
+fi
+# remaining arguments: service <service> account <account>
+file="$FAKE_KEYRING/$2-$4"
+case "$action" in
+store) cat > "$file" ;;
+lookup) [ -f "$file" ] || exit 1; cat "$file" ;;
+clear) rm -f "$file" ;;
+*) echo "unknown action $action" >&2; exit 2 ;;
+esac
+`
+
+func newFakeKeyring(t *testing.T) sessionStore {
+	t.Helper()
+
+	var dir = t.TempDir()
+	var bin = filepath.Join(dir, "secret-tool")
+	if err := os.WriteFile(bin, []byte(fakeSecretTool), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var keyring = filepath.Join(dir, "keyring")
+	if err := os.Mkdir(keyring, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("FAKE_KEYRING", keyring)
+	t.Setenv("DNOCS_SECRET_TOOL", bin)
+	t.Setenv("DNOCS_SESSION_STORE", "")
+
+	store, err := newSessionStore(&Config{SessionStore: SessionStoreKeyring})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func TestKeyringSessionStore(t *testing.T) {
+	store := newFakeKeyring(t)
+
+	// not found
+	_, err := store.read("session")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("read() missing session error = %v, want fs.ErrNotExist", err)
+	}
+
+	// store and lookup
+	if err := store.write("session", "cookie-value"); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+
+	value, err := store.read("session")
+	if err != nil {
+		t.Fatalf("read() error = %v", err)
+	}
+	if value != "cookie-value" {
+		t.Errorf("read() = %q, want cookie-value", value)
+	}
+
+	// every profile has its own secret
+	if err := store.write("session-dev", "dev-cookie"); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	if value, _ := store.read("session"); value != "cookie-value" {
+		t.Errorf("read() after writing other profile = %q, want cookie-value", value)
+	}
+
+	// clear
+	if err := store.remove("session"); err != nil {
+		t.Fatalf("remove() error = %v", err)
+	}
+
+	_, err = store.read("session")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("read() removed session error = %v, want fs.ErrNotExist", err)
+	}
+
+	if value, _ := store.read("session-dev"); value != "dev-cookie" {
+		t.Errorf("read() other profile after remove = %q, want dev-cookie", value)
+	}
+}
+
+func TestKeyringSessionStoreMissingBinary(t *testing.T) {
+	t.Setenv("DNOCS_SECRET_TOOL", filepath.Join(t.TempDir(), "not-installed"))
+	t.Setenv("DNOCS_SESSION_STORE", "")
+
+	store, err := newSessionStore(&Config{SessionStore: SessionStoreKeyring})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.write("session", "cookie-value"); err == nil {
+		t.Error("write() without secret-tool, want error")
+	}
+}
+
+func TestFileSessionStoreRepairPermission(t *testing.T) {
+	var home = t.TempDir()
+	t.Setenv("HOME", home)
+
+	// session written by older version
+	var devetekDir = filepath.Join(home, ".devetek")
+	if err := os.Mkdir(devetekDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(devetekDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var sessionFile = filepath.Join(devetekDir, "session")
+	if err := os.WriteFile(sessionFile, []byte("cookie-value"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(sessionFile, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := (&fileSessionStore{}).read("session")
+	if err != nil {
+		t.Fatalf("read() error = %v", err)
+	}
+	if value != "cookie-value" {
+		t.Errorf("read() = %q, want cookie-value", value)
+	}
+
+	for path, want := range map[string]os.FileMode{devetekDir: 0700, sessionFile: 0600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("permission of %s = %v, want %v", path, info.Mode().Perm(), want)
+		}
+	}
+}
+
+func TestFileSessionStoreWritePermission(t *testing.T) {
+	var home = t.TempDir()
+	t.Setenv("HOME", home)
+
+	store := &fileSessionStore{}
+	if err := store.write("session", "cookie-value"); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(home, ".devetek", "session"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("permission = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := store.remove("session"); err != nil {
+		t.Fatalf("remove() error = %v", err)
+	}
+	if err := store.remove("session"); err != nil {
+		t.Errorf("remove() missing session error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// read personal access token, from LoginToken, DNOCS_TOKEN, then session store
+func (c *Client) readToken() (string, error) {
+	if c.token != "" {
+		return c.token, nil
+	}
+
+	if token := os.Getenv("DNOCS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	if c.configErr != nil {
+		return "", c.configErr
+	}
+
+	token, err := c.sessions.read(c.tokenName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(token), nil
+}
+
+func (c *Client) removeToken() error {
+	if c.configErr != nil {
+		return c.configErr
+	}
+
+	return c.sessions.remove(c.tokenName)
+}
+
+// check if request authorized with personal access token instead of session cookie
+func (c *Client) UsesToken() bool {
+	token, err := c.readToken()
+
+	return err == nil && token != ""
+}
+
+// check if token come from DNOCS_TOKEN, can not be removed by logout
+func (c *Client) UsesEnvToken() bool {
+	return c.token == "" && os.Getenv("DNOCS_TOKEN") != ""
+}
+
+// set credential to request header, token sent as bearer authorization,
+// otherwise session sent as cookie with cookie name dcloud_sid
+func (c *Client) authorize(req *http.Request) error {
+	token, err := c.readToken()
+	if err != nil {
+		return err
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+
+	cookieValue, err := c.readSession()
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Cookie", "dcloud_sid="+cookieValue)
+
+	return nil
+}
+
+// validate personal access token to dPanel, then store it in session store
+func (c *Client) LoginToken(token string) (*jsonResponseUser, error) {
+	return c.LoginTokenContext(context.Background(), token)
+}
+
+// validate personal access token to dPanel with context, then store it in session store
+func (c *Client) LoginTokenContext(ctx context.Context, token string) (*jsonResponseUser, error) {
+	if c.configErr != nil {
+		return nil, c.configErr
+	}
+
+	c.token = strings.TrimSpace(token)
+	defer func() {
+		c.token = ""
+	}()
+
+	profile, err := c.GetProfileContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.sessions.write(c.tokenName, c.token)
+	if err != nil {
+		return nil, err
+	}
+
+	// token replace session, old session is no longer used
+	err = c.sessions.remove(c.sessionName)
+	if err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
@@ -1,11 +1,9 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
-	"strings"
-	"time"
 
 	"github.com/devetek/d-panel/pkg/duser"
 )
@@ -23,6 +21,11 @@ type loginAPIv0Deprecated struct {
 	AvatarURL    string `json:"avatar_url"`
 }
 
+type loginPayload struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
 type jsonResponseLogin struct {
 	Code   int                  `json:"code"`
 	Status string               `json:"status,omitempty"`
@@ -38,39 +41,25 @@ type jsonResponseUser struct {
 }
 
 func (c *Client) Login(email, password string) (*jsonResponseLogin, error) {
-	url := c.BaseURL + "/api/v0/user/login"
-	jsonStr := fmt.Sprintf(`{"email":"%s","password":"%s"}`, email, password)
-
-	httpClient := &http.Client{
-		Timeout: time.Second * 5,
-	}
-	req, err := http.NewRequest("POST", url, strings.NewReader(jsonStr))
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	return c.LoginContext(context.Background(), email, password)
+}
 
-	// read response body with json decoder
+// login with context
+func (c *Client) LoginContext(ctx context.Context, email, password string) (*jsonResponseLogin, error) {
 	var loginStatus = new(jsonResponseLogin)
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(loginStatus)
+	resp, err := c.do(ctx, apiRequest{
+		method: http.MethodPost,
+		path:   "/api/v0/user/login",
+		payload: loginPayload{
+			Email:    email,
+			Password: password,
+		},
+		anonymous: true,
+	}, loginStatus)
 	if err != nil {
 		return nil, err
 	}
 
-	if loginStatus.Error != "" {
-		return nil, fmt.Errorf("%s", loginStatus.Error)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
-	}
-
 	// read response header
 	cookie := resp.Header.Get("Set-Cookie")
 	if cookie == "" {
@@ -92,56 +81,54 @@ func (c *Client) Login(email, password string) (*jsonResponseLogin, error) {
 		}
 	}
 
-	err = c.writeCookieToFile(cookieValue)
-	if err != nil {
-		return nil, err
+	if cookieValue == "" {
+		return nil, fmt.Errorf("no session found")
 	}
 
-	return loginStatus, nil
-}
-
-// fetch API get user profile
-func (c *Client) GetProfile() (*jsonResponseUser, error) {
-	cookieValue, err := c.readCookieFromFile()
+	err = c.writeSession(cookieValue)
 	if err != nil {
 		return nil, err
 	}
 
-	url := c.BaseURL + "/api/v1/user/profile"
-	httpClient := &http.Client{
-		Timeout: time.Second * 5,
-	}
-
-	req, err := http.NewRequest("GET", url, nil)
+	// stored token has priority over session, remove it so the new session is used
+	err = c.removeToken()
 	if err != nil {
 		return nil, err
 	}
 
-	// set cookie to request header, with cookie name dcloud_sid
-	req.Header.Set("Cookie", "dcloud_sid="+cookieValue)
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	return loginStatus, nil
+}
 
-	// read response body
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
+// fetch API get user profile
+func (c *Client) GetProfile() (*jsonResponseUser, error) {
+	return c.GetProfileContext(context.Background())
+}
 
-	// read response body with json decoder
+// fetch API get user profile with context
+func (c *Client) GetProfileContext(ctx context.Context) (*jsonResponseUser, error) {
 	var profile = new(jsonResponseUser)
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(profile)
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodGet,
+		path:   "/api/v1/user/profile",
+	}, profile)
 	if err != nil {
 		return nil, err
 	}
 
-	if profile.Error != "" {
-		return nil, fmt.Errorf("%s", profile.Error)
-	}
-
 	return profile, nil
 }
+
+// invalidate current session in dPanel, local session is not removed
+func (c *Client) Logout() error {
+	return c.LogoutContext(context.Background())
+}
+
+// invalidate current session in dPanel with context
+func (c *Client) LogoutContext(ctx context.Context) error {
+	_, err := c.do(ctx, apiRequest{
+		method: http.MethodPost,
+		path:   "/api/v1/user/logout",
+	}, nil)
+
+	return err
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// client with empty home folder, session written by the test never touch the real one
+func newTestClient(t *testing.T, baseURL string) *Client {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("DNOCS_TOKEN", "")
+	t.Setenv("DNOCS_API_BASE_URL", "")
+	t.Setenv("DNOCS_SESSION_STORE", "")
+
+	client := NewClient()
+	client.BaseURL = baseURL
+	client.Retry.MaxAttempts = 1
+
+	return client
+}
+
+func TestLoginContextPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+	}{
+		{name: "plain", password: "secret"},
+		{name: "double quote", password: `pa"ss"word`},
+		{name: "json injection", password: `x","email":"attacker@example.com`},
+		{name: "backslash", password: `C:\path\to\"secret"\`},
+		{name: "control characters", password: "tab\tnew\nline\rnull\x00bell\x07"},
+		{name: "unicode", password: "päss🔑\u2028word"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received loginPayload
+			var contentType string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get("Content-Type")
+
+				if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+					t.Errorf("decoding login body: %v", err)
+				}
+
+				http.SetCookie(w, &http.Cookie{Name: "dcloud_sid", Value: "session-value"})
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"code":200,"status":"OK","data":{"email":"user@example.com"}}`))
+			}))
+			defer server.Close()
+
+			client := newTestClient(t, server.URL)
+
+			_, err := client.LoginContext(context.Background(), "user@example.com", tt.password)
+			if err != nil {
+				t.Fatalf("LoginContext() error = %v", err)
+			}
+
+			if contentType != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", contentType)
+			}
+
+			if received.Email != "user@example.com" {
+				t.Errorf("email = %q, want user@example.com", received.Email)
+			}
+
+			if received.Password != tt.password {
+				t.Errorf("password = %q, want %q", received.Password, tt.password)
+			}
+		})
+	}
+}
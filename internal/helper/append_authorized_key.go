@@ -1,36 +1,15 @@
 package helper
 
-import "os"
+import "os/user"
 
-// function to append ssh key to authorized_keys file
+// function to append ssh key to authorized_keys file of current user, key already exist is not duplicated
 func AppendAuthorizedKey(sshKey string) error {
-	// create file if not exist
-	_, err := os.Stat(os.Getenv("HOME") + "/.ssh/authorized_keys")
-	if os.IsNotExist(err) {
-		err = os.MkdirAll(os.Getenv("HOME")+"/.ssh", 0700)
-		if err != nil {
-			return err
-		}
-		err = os.WriteFile(os.Getenv("HOME")+"/.ssh/authorized_keys", []byte{}, 0644)
-		if err != nil {
-			return err
-		}
-	}
-
-	// read authorized_keys from current user
-	authorizedKeys, err := os.ReadFile(os.Getenv("HOME") + "/.ssh/authorized_keys")
+	currentUser, err := user.Current()
 	if err != nil {
 		return err
 	}
 
-	// append ssh key to authorized_keys file
-	authorizedKeys = append(authorizedKeys, []byte(sshKey+"\n")...)
-
-	// write authorized_keys file
-	err = os.WriteFile(os.Getenv("HOME")+"/.ssh/authorized_keys", authorizedKeys, 0644)
-	if err != nil {
-		return err
-	}
+	_, err = AuthorizeKey(currentUser, sshKey, AuthorizeOptions{})
 
-	return nil
+	return err
 }
@@ -0,0 +1,407 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// comment tag of key added by dnocs, key with this tag is managed by dnocs
+const AuthorizedKeyTag = "dnocs-managed"
+
+// AuthorizedKey is one key in authorized_keys file
+type AuthorizedKey struct {
+	// e.g. from="10.0.0.0/8", no-port-forwarding, command="/bin/true"
+	Options []string
+	Type    string
+	// base64 encoded public key
+	Key     string
+	Comment string
+}
+
+// ParseAuthorizedKey parse one line of authorized_keys, with or without options
+func ParseAuthorizedKey(line string) (*AuthorizedKey, error) {
+	publicKey, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthorizedKey{
+		Options: options,
+		Type:    publicKey.Type(),
+		Key:     base64.StdEncoding.EncodeToString(publicKey.Marshal()),
+		Comment: comment,
+	}, nil
+}
+
+// line in authorized_keys format
+func (k AuthorizedKey) String() string {
+	var fields []string
+	if len(k.Options) > 0 {
+		fields = append(fields, strings.Join(k.Options, ","))
+	}
+
+	fields = append(fields, k.Type, k.Key)
+
+	if k.Comment != "" {
+		fields = append(fields, k.Comment)
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// key added by dnocs
+func (k AuthorizedKey) Managed() bool {
+	for _, word := range strings.Fields(k.Comment) {
+		if word == AuthorizedKeyTag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compare public key only, options and comment are ignored
+func (k AuthorizedKey) SameKey(other AuthorizedKey) bool {
+	return k.Type == other.Type && k.Key == other.Key
+}
+
+// line of authorized_keys, comment or unknown line is kept as is
+type authorizedKeysLine struct {
+	key *AuthorizedKey
+	raw string
+}
+
+// AuthorizedKeys is authorized_keys file of a user, keep order, comments, and lines not managed by dnocs
+type AuthorizedKeys struct {
+	Path  string
+	owner *user.User
+	lines []authorizedKeysLine
+}
+
+// AuthorizeOptions restrict key added to authorized_keys
+type AuthorizeOptions struct {
+	// source address allowed to use the key, IP, CIDR or host pattern
+	From []string
+	// other options, e.g. no-port-forwarding
+	Options []string
+}
+
+// options written in authorized_keys
+func (o AuthorizeOptions) keyOptions() []string {
+	var options []string
+	if len(o.From) > 0 {
+		options = append(options, fmt.Sprintf("from=%q", strings.Join(o.From, ",")))
+	}
+
+	return append(options, o.Options...)
+}
+
+// AuthorizedKeysPath return authorized_keys file of the user, from AuthorizedKeysFile in sshd_config
+func AuthorizedKeysPath(owner *user.User) string {
+	var authorizedKeysFile = ".ssh/authorized_keys"
+
+	if config, err := ReadSSHDConfig(SSHDConfigPath); err == nil {
+		// first file is used, sshd also read the others
+		if files := strings.Fields(config.Get("AuthorizedKeysFile", "")); len(files) > 0 && files[0] != "none" {
+			authorizedKeysFile = files[0]
+		}
+	}
+
+	authorizedKeysFile = expandSSHDTokens(authorizedKeysFile, owner)
+	if !filepath.IsAbs(authorizedKeysFile) {
+		authorizedKeysFile = filepath.Join(owner.HomeDir, authorizedKeysFile)
+	}
+
+	return authorizedKeysFile
+}
+
+// expand %h, %u, %U and %% in sshd_config path
+func expandSSHDTokens(value string, owner *user.User) string {
+	var result strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' || i == len(value)-1 {
+			result.WriteByte(value[i])
+			continue
+		}
+
+		i++
+		switch value[i] {
+		case 'h':
+			result.WriteString(owner.HomeDir)
+		case 'u':
+			result.WriteString(owner.Username)
+		case 'U':
+			result.WriteString(owner.Uid)
+		case '%':
+			result.WriteByte('%')
+		default:
+			result.WriteByte('%')
+			result.WriteByte(value[i])
+		}
+	}
+
+	return result.String()
+}
+
+// LoadAuthorizedKeys read authorized_keys file of the user, empty when the file not exist
+func LoadAuthorizedKeys(owner *user.User) (*AuthorizedKeys, error) {
+	return loadAuthorizedKeysFile(AuthorizedKeysPath(owner), owner)
+}
+
+func loadAuthorizedKeysFile(path string, owner *user.User) (*AuthorizedKeys, error) {
+	var keys = &AuthorizedKeys{
+		Path:  path,
+		owner: owner,
+	}
+
+	content, err := os.ReadFile(keys.Path)
+	if os.IsNotExist(err) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// empty file has no line, not one empty line
+	var text = strings.TrimRight(string(content), "\n")
+	if text == "" {
+		return keys, nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		var trimmed = strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			keys.lines = append(keys.lines, authorizedKeysLine{raw: line})
+			continue
+		}
+
+		key, err := ParseAuthorizedKey(trimmed)
+		if err != nil {
+			// keep line unknown to the parser, sshd may still understand it
+			keys.lines = append(keys.lines, authorizedKeysLine{raw: line})
+			continue
+		}
+
+		keys.lines = append(keys.lines, authorizedKeysLine{key: key, raw: line})
+	}
+
+	return keys, nil
+}
+
+// Keys return every key in the file
+func (a *AuthorizedKeys) Keys() []AuthorizedKey {
+	var keys []AuthorizedKey
+	for _, line := range a.lines {
+		if line.key != nil {
+			keys = append(keys, *line.key)
+		}
+	}
+
+	return keys
+}
+
+// Find return the key with the same public key, nil when not found
+func (a *AuthorizedKeys) Find(publicKey string) *AuthorizedKey {
+	key, err := ParseAuthorizedKey(publicKey)
+	if err != nil {
+		return nil
+	}
+
+	for _, line := range a.lines {
+		if line.key != nil && line.key.SameKey(*key) {
+			return line.key
+		}
+	}
+
+	return nil
+}
+
+// Add add public key tagged as managed by dnocs, duplicate of the key is removed.
+// Key added by user is kept as is unless options are given, managed key is updated with the new options.
+// Return true when the file changed.
+func (a *AuthorizedKeys) Add(publicKey string, opts AuthorizeOptions) (bool, error) {
+	key, err := ParseAuthorizedKey(publicKey)
+	if err != nil {
+		return false, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	key.Options = opts.keyOptions()
+	if !key.Managed() {
+		key.Comment = strings.TrimSpace(key.Comment + " " + AuthorizedKeyTag)
+	}
+
+	var changed bool
+	var found bool
+	var lines []authorizedKeysLine
+	for _, line := range a.lines {
+		if line.key == nil || !line.key.SameKey(*key) {
+			lines = append(lines, line)
+			continue
+		}
+
+		// remove duplicate
+		if found {
+			changed = true
+			continue
+		}
+		found = true
+
+		if (line.key.Managed() || len(key.Options) > 0) && line.key.String() != key.String() {
+			line = authorizedKeysLine{key: key, raw: key.String()}
+			changed = true
+		}
+		lines = append(lines, line)
+	}
+
+	if !found {
+		lines = append(lines, authorizedKeysLine{key: key, raw: key.String()})
+		changed = true
+	}
+
+	a.lines = lines
+
+	return changed, nil
+}
+
+// Remove remove entries of the public key tagged as managed by dnocs, return true when removed.
+// Entry of the same key added by user is kept.
+func (a *AuthorizedKeys) Remove(publicKey string) bool {
+	key, err := ParseAuthorizedKey(publicKey)
+	if err != nil {
+		return false
+	}
+
+	var removed bool
+	var lines []authorizedKeysLine
+	for _, line := range a.lines {
+		if line.key != nil && line.key.SameKey(*key) && line.key.Managed() {
+			removed = true
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	a.lines = lines
+
+	return removed
+}
+
+// Save write authorized_keys atomically with 0600 permission, owned by the user.
+// Folder of the file is created with 0700 permission when not exist.
+func (a *AuthorizedKeys) Save() error {
+	var dir = filepath.Dir(a.Path)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			return err
+		}
+
+		err = a.chown(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	var content bytes.Buffer
+	for _, line := range a.lines {
+		content.WriteString(line.raw)
+		content.WriteString("\n")
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(a.Path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if err := tmpFile.Chmod(0600); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if _, err := tmpFile.Write(content.Bytes()); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err := a.chown(tmpFile.Name()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), a.Path)
+}
+
+// sshd refuse authorized_keys owned by other user, root can write file of other user
+func (a *AuthorizedKeys) chown(name string) error {
+	if os.Geteuid() != 0 || a.owner == nil {
+		return nil
+	}
+
+	uid, err := strconv.Atoi(a.owner.Uid)
+	if err != nil {
+		return nil
+	}
+
+	gid, err := strconv.Atoi(a.owner.Gid)
+	if err != nil {
+		return nil
+	}
+
+	return os.Chown(name, uid, gid)
+}
+
+// AuthorizeKey add public key to authorized_keys of the user, return true when the key is added or updated
+func AuthorizeKey(owner *user.User, publicKey string, opts AuthorizeOptions) (bool, error) {
+	keys, err := LoadAuthorizedKeys(owner)
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := keys.Add(publicKey, opts)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	return true, keys.Save()
+}
+
+// UnauthorizeKey remove public key managed by dnocs from authorized_keys of the user, return true when the key removed
+func UnauthorizeKey(owner *user.User, publicKey string) (bool, error) {
+	keys, err := LoadAuthorizedKeys(owner)
+	if err != nil {
+		return false, err
+	}
+
+	if !keys.Remove(publicKey) {
+		return false, nil
+	}
+
+	return true, keys.Save()
+}
+
+// IsKeyAuthorized check if public key exist in authorized_keys of the user
+func IsKeyAuthorized(owner *user.User, publicKey string) bool {
+	keys, err := LoadAuthorizedKeys(owner)
+	if err != nil {
+		return false
+	}
+
+	return keys.Find(publicKey) != nil
+}
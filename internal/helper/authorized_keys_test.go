@@ -0,0 +1,345 @@
+package helper
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// new ed25519 public key in authorized_keys format, with comment
+func testPublicKey(t *testing.T, comment string) string {
+	t.Helper()
+
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshKey))) + " " + comment
+}
+
+// public key without comment, as written by AuthorizedKey.String
+func keyBlob(publicKey string) string {
+	fields := strings.Fields(publicKey)
+	return fields[0] + " " + fields[1]
+}
+
+// write authorized_keys and load it
+func loadTestAuthorizedKeys(t *testing.T, content string) *AuthorizedKeys {
+	t.Helper()
+
+	var path = filepath.Join(t.TempDir(), ".ssh", "authorized_keys")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := loadAuthorizedKeysFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keys
+}
+
+func saveAndRead(t *testing.T, keys *AuthorizedKeys) string {
+	t.Helper()
+
+	if err := keys.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	info, err := os.Stat(keys.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("permission = %v, want 0600", info.Mode().Perm())
+	}
+
+	content, err := os.ReadFile(keys.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestParseAuthorizedKey(t *testing.T) {
+	var key = testPublicKey(t, "user@laptop")
+	var blob = keyBlob(key)
+
+	tests := []struct {
+		name        string
+		line        string
+		wantOptions []string
+		wantComment string
+		wantErr     bool
+	}{
+		{name: "without options", line: key, wantComment: "user@laptop"},
+		{name: "without comment", line: blob},
+		{name: "flag option", line: "no-port-forwarding " + blob, wantOptions: []string{"no-port-forwarding"}},
+		{
+			name:        "quoted options with comma and space",
+			line:        `from="10.0.0.1,192.168.0.0/16",command="echo a, b",no-pty ` + key,
+			wantOptions: []string{`from="10.0.0.1,192.168.0.0/16"`, `command="echo a, b"`, "no-pty"},
+			wantComment: "user@laptop",
+		},
+		{name: "managed tag", line: blob + " user@laptop " + AuthorizedKeyTag, wantComment: "user@laptop " + AuthorizedKeyTag},
+		{name: "invalid key", line: "ssh-ed25519 not-base64", wantErr: true},
+		{name: "comment line", line: "# ssh-ed25519 AAAA", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAuthorizedKey(tt.line)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseAuthorizedKey() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAuthorizedKey() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got.Options, tt.wantOptions) {
+				t.Errorf("Options = %q, want %q", got.Options, tt.wantOptions)
+			}
+			if got.Comment != tt.wantComment {
+				t.Errorf("Comment = %q, want %q", got.Comment, tt.wantComment)
+			}
+			if got.Type+" "+got.Key != blob {
+				t.Errorf("key = %q, want %q", got.Type+" "+got.Key, blob)
+			}
+		})
+	}
+}
+
+func TestAuthorizedKeysAdd(t *testing.T) {
+	var dpanel = testPublicKey(t, "dpanel")
+	var other = testPublicKey(t, "other@laptop")
+	var blob = keyBlob(dpanel)
+	var managed = blob + " dpanel " + AuthorizedKeyTag
+
+	tests := []struct {
+		name        string
+		content     string
+		opts        AuthorizeOptions
+		wantChanged bool
+		want        string
+	}{
+		{
+			name:        "empty file",
+			content:     "",
+			wantChanged: true,
+			want:        managed + "\n",
+		},
+		{
+			name:        "keep comments, other keys and unparsable lines",
+			content:     "# my keys\n\n" + other + "\ngarbage line\n",
+			wantChanged: true,
+			want:        "# my keys\n\n" + other + "\ngarbage line\n" + managed + "\n",
+		},
+		{
+			name:        "file without trailing newline",
+			content:     other,
+			wantChanged: true,
+			want:        other + "\n" + managed + "\n",
+		},
+		{
+			name:    "managed key already exist",
+			content: managed + "\n",
+			want:    managed + "\n",
+		},
+		{
+			name:        "duplicate removed",
+			content:     managed + "\n" + other + "\n" + managed + "\n" + dpanel + "\n",
+			wantChanged: true,
+			want:        managed + "\n" + other + "\n",
+		},
+		{
+			name:    "key added by user kept",
+			content: "no-pty " + dpanel + "\n",
+			want:    "no-pty " + dpanel + "\n",
+		},
+		{
+			name:        "key added by user restricted when options given",
+			content:     dpanel + "\n",
+			opts:        AuthorizeOptions{From: []string{"203.0.113.10", "198.51.100.0/24"}},
+			wantChanged: true,
+			want:        `from="203.0.113.10,198.51.100.0/24" ` + managed + "\n",
+		},
+		{
+			name:        "managed key updated with new options",
+			content:     `from="10.0.0.1" ` + managed + "\n",
+			opts:        AuthorizeOptions{Options: []string{"no-port-forwarding"}},
+			wantChanged: true,
+			want:        "no-port-forwarding " + managed + "\n",
+		},
+		{
+			name:        "managed restriction removed",
+			content:     `from="10.0.0.1" ` + managed + "\n",
+			wantChanged: true,
+			want:        managed + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := loadTestAuthorizedKeys(t, tt.content)
+
+			changed, err := keys.Add(dpanel, tt.opts)
+			if err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("Add() changed = %v, want %v", changed, tt.wantChanged)
+			}
+
+			if got := saveAndRead(t, keys); got != tt.want {
+				t.Errorf("file content:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizedKeysAddInvalidKey(t *testing.T) {
+	keys := loadTestAuthorizedKeys(t, "")
+
+	if _, err := keys.Add("ssh-ed25519 not-base64", AuthorizeOptions{}); err == nil {
+		t.Error("Add() with invalid key, want error")
+	}
+}
+
+func TestAuthorizedKeysRemove(t *testing.T) {
+	var dpanel = testPublicKey(t, "dpanel")
+	var other = testPublicKey(t, "other@laptop")
+	var managed = keyBlob(dpanel) + " dpanel " + AuthorizedKeyTag
+
+	tests := []struct {
+		name        string
+		content     string
+		wantRemoved bool
+		want        string
+	}{
+		{
+			name:        "managed key",
+			content:     "# my keys\n" + other + "\n" + managed + "\n",
+			wantRemoved: true,
+			want:        "# my keys\n" + other + "\n",
+		},
+		{
+			name:        "managed key with options and duplicate",
+			content:     `from="10.0.0.1" ` + managed + "\n" + managed + "\n",
+			wantRemoved: true,
+			want:        "",
+		},
+		{
+			name:    "key added by user kept",
+			content: dpanel + "\n" + other + "\n",
+			want:    dpanel + "\n" + other + "\n",
+		},
+		{
+			name:        "only managed entry of the same key removed",
+			content:     "no-pty " + dpanel + "\n" + managed + "\n",
+			wantRemoved: true,
+			want:        "no-pty " + dpanel + "\n",
+		},
+		{
+			name:    "key not found",
+			content: other + "\ngarbage line\n",
+			want:    other + "\ngarbage line\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := loadTestAuthorizedKeys(t, tt.content)
+
+			if removed := keys.Remove(dpanel); removed != tt.wantRemoved {
+				t.Errorf("Remove() = %v, want %v", removed, tt.wantRemoved)
+			}
+
+			if got := saveAndRead(t, keys); got != tt.want {
+				t.Errorf("file content:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizedKeysFind(t *testing.T) {
+	var dpanel = testPublicKey(t, "dpanel")
+	var other = testPublicKey(t, "other")
+
+	keys := loadTestAuthorizedKeys(t, `from="10.0.0.1" `+keyBlob(dpanel)+" renamed\n")
+
+	found := keys.Find(dpanel)
+	if found == nil {
+		t.Fatal("Find() = nil, want key with different comment and options")
+	}
+	if !reflect.DeepEqual(found.Options, []string{`from="10.0.0.1"`}) {
+		t.Errorf("Options = %q", found.Options)
+	}
+
+	if keys.Find(other) != nil {
+		t.Error("Find() found key not in the file")
+	}
+}
+
+func TestAuthorizedKeysSaveCreateFolder(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), ".ssh", "authorized_keys")
+
+	keys, err := loadAuthorizedKeysFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keys.Add(testPublicKey(t, "dpanel"), AuthorizeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	saveAndRead(t, keys)
+
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("folder permission = %v, want 0700", info.Mode().Perm())
+	}
+}
+
+func TestExpandSSHDTokens(t *testing.T) {
+	var owner = &user.User{Username: "deploy", Uid: "1001", HomeDir: "/home/deploy"}
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: ".ssh/authorized_keys", want: ".ssh/authorized_keys"},
+		{value: "%h/.ssh/authorized_keys", want: "/home/deploy/.ssh/authorized_keys"},
+		{value: "/etc/ssh/keys/%u", want: "/etc/ssh/keys/deploy"},
+		{value: "/etc/ssh/keys/%U/100%%", want: "/etc/ssh/keys/1001/100%"},
+		{value: "/etc/%x/%", want: "/etc/%x/%"},
+	}
+
+	for _, tt := range tests {
+		if got := expandSSHDTokens(tt.value, owner); got != tt.want {
+			t.Errorf("expandSSHDTokens(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
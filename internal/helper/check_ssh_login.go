@@ -0,0 +1,50 @@
+package helper
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// check if sshd at address accept login of user with the private key
+func CheckSSHLogin(ctx context.Context, address string, user string, privateKey []byte) error {
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	config := &ssh.ClientConfig{
+		User: user,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		// only used to check authorized key of this machine, host key is not verified
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         10 * time.Second,
+	}
+
+	dialer := net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// handshake must not block longer than the timeout
+	if err := conn.SetDeadline(time.Now().Add(config.Timeout)); err != nil {
+		return err
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		return err
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		for newChan := range chans {
+			_ = newChan.Reject(ssh.Prohibited, "")
+		}
+	}()
+
+	return sshConn.Close()
+}
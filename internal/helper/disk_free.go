@@ -0,0 +1,17 @@
+//go:build unix
+
+package helper
+
+import "syscall"
+
+// free disk space in bytes for unprivileged user, of file system where path located
+func DiskFree(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package helper
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// free disk space in bytes for unprivileged user, of file system where path located
+func DiskFree(path string) (uint64, error) {
+	return 0, fmt.Errorf("checking disk space is not supported on %s", runtime.GOOS)
+}
@@ -1,6 +1,7 @@
 package helper
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 )
@@ -12,7 +13,17 @@ type jsonResponseIP struct {
 
 // func to get my public IP
 func GetMyIP() (string, error) {
-	resp, err := http.Get("https://api.ipify.org?format=json")
+	return GetMyIPContext(context.Background())
+}
+
+// func to get my public IP with context
+func GetMyIPContext(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.ipify.org?format=json", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,28 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// check if process with the given name running, read from /proc
+func IsProcessRunning(name string) bool {
+	comms, err := filepath.Glob("/proc/[0-9]*/comm")
+	if err != nil {
+		return false
+	}
+
+	for _, comm := range comms {
+		content, err := os.ReadFile(comm)
+		if err != nil {
+			continue
+		}
+
+		if strings.TrimSpace(string(content)) == name {
+			return true
+		}
+	}
+
+	return false
+}
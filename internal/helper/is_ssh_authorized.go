@@ -1,22 +1,13 @@
 package helper
 
-import (
-	"os"
-	"strings"
-)
+import "os/user"
 
-// func to check if SSH pub key authorized
+// func to check if SSH pub key authorized for current user
 func IsSSHAuthorized(str string) bool {
-	// read file content
-	content, err := os.ReadFile(os.Getenv("HOME") + "/.ssh/authorized_keys")
+	currentUser, err := user.Current()
 	if err != nil {
 		return false
 	}
 
-	// check if key exist in file content
-	if strings.Contains(string(content), str) {
-		return true
-	}
-
-	return false
+	return IsKeyAuthorized(currentUser, str)
 }
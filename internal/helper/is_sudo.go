@@ -8,7 +8,9 @@ import (
 func IsSudo() bool {
 	/**
 	*	Todo:
-	*	Remove require root as dpanel executor. But it need to update some patchs:
+	*	Remove require root as dpanel executor. dPanel can already login with non root user
+	*	registered by 'dnocs machine create --ssh-user', which run command as root through sudo,
+	*	but dpanel-agent still running under root. It need to update some patchs:
 	*	- dpanel-init role
 	*	- dpanel-agent role
 	 */
@@ -0,0 +1,21 @@
+package helper
+
+import (
+	"os/user"
+	"strings"
+)
+
+// function to remove ssh key added by dnocs from authorized_keys file of current user, return true when the key removed
+func RemoveAuthorizedKey(sshKey string) (bool, error) {
+	sshKey = strings.TrimSpace(sshKey)
+	if sshKey == "" {
+		return false, nil
+	}
+
+	currentUser, err := user.Current()
+	if err != nil {
+		return false, err
+	}
+
+	return UnauthorizeKey(currentUser, sshKey)
+}
@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ValidateSSHKeyPair check private key in PEM or OpenSSH format, and public key in authorized_keys format.
+// Public key is derived from the private key when empty, the returned public key is in authorized_keys format.
+func ValidateSSHKeyPair(privateKey, publicKey []byte) (string, error) {
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		var passphraseErr *ssh.PassphraseMissingError
+		if errors.As(err, &passphraseErr) {
+			return "", fmt.Errorf("private key is protected by passphrase, remove it with 'ssh-keygen -p' first")
+		}
+		return "", fmt.Errorf("invalid private key: %w", err)
+	}
+
+	if len(bytes.TrimSpace(publicKey)) == 0 {
+		return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))), nil
+	}
+
+	parsed, comment, _, _, err := ssh.ParseAuthorizedKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %w", err)
+	}
+
+	if !bytes.Equal(parsed.Marshal(), signer.PublicKey().Marshal()) {
+		return "", fmt.Errorf("public key does not match the private key")
+	}
+
+	var authorizedKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(parsed)))
+	if comment != "" {
+		authorizedKey += " " + comment
+	}
+
+	return authorizedKey, nil
+}
+
+// SSHFingerprint return SHA256 fingerprint of public key in authorized_keys format, empty when the key is invalid
+func SSHFingerprint(publicKey string) string {
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return ""
+	}
+
+	return ssh.FingerprintSHA256(parsed)
+}
@@ -0,0 +1,136 @@
+package helper
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// private key in OpenSSH format and its public key in authorized_keys format, without comment
+func testKeyPair(t *testing.T, passphrase string) ([]byte, string) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(privateKey, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(privateKey, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(block), strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshKey)))
+}
+
+// RSA private key in PKCS#1 PEM format, as written by older ssh-keygen
+func testRSAKeyPair(t *testing.T) ([]byte, string) {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}
+
+	return pem.EncodeToMemory(block), strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshKey)))
+}
+
+func TestValidateSSHKeyPair(t *testing.T) {
+	privateKey, publicKey := testKeyPair(t, "")
+	rsaPrivateKey, rsaPublicKey := testRSAKeyPair(t)
+	protectedKey, protectedPublicKey := testKeyPair(t, "secret")
+	_, otherPublicKey := testKeyPair(t, "")
+
+	tests := []struct {
+		name       string
+		privateKey []byte
+		publicKey  string
+		want       string
+		wantErr    string
+	}{
+		{name: "public key derived", privateKey: privateKey, want: publicKey},
+		{name: "public key derived from RSA PEM", privateKey: rsaPrivateKey, want: rsaPublicKey},
+		{name: "empty public key file", privateKey: privateKey, publicKey: " \n", want: publicKey},
+		{name: "matching public key", privateKey: privateKey, publicKey: publicKey + "\n", want: publicKey},
+		{name: "comment kept", privateKey: privateKey, publicKey: publicKey + " team@laptop\n", want: publicKey + " team@laptop"},
+		{name: "passphrase", privateKey: protectedKey, publicKey: protectedPublicKey, wantErr: "protected by passphrase"},
+		{name: "mismatched public key", privateKey: privateKey, publicKey: otherPublicKey, wantErr: "does not match"},
+		{name: "invalid public key", privateKey: privateKey, publicKey: "ssh-ed25519 not-base64", wantErr: "invalid public key"},
+		{name: "public key given as private key", privateKey: []byte(publicKey), wantErr: "invalid private key"},
+		{name: "empty private key", privateKey: nil, wantErr: "invalid private key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateSSHKeyPair(tt.privateKey, []byte(tt.publicKey))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ValidateSSHKeyPair() = %q, %v, want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateSSHKeyPair() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ValidateSSHKeyPair() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSHFingerprint(t *testing.T) {
+	_, publicKey := testKeyPair(t, "")
+
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		publicKey string
+		want      string
+	}{
+		{name: "without comment", publicKey: publicKey, want: ssh.FingerprintSHA256(parsed)},
+		{name: "comment ignored", publicKey: publicKey + " team@laptop", want: ssh.FingerprintSHA256(parsed)},
+		{name: "invalid", publicKey: "ssh-ed25519 not-base64"},
+		{name: "empty", publicKey: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SSHFingerprint(tt.publicKey)
+			if got != tt.want {
+				t.Errorf("SSHFingerprint() = %q, want %q", got, tt.want)
+			}
+			if tt.want != "" && !strings.HasPrefix(got, "SHA256:") {
+				t.Errorf("SSHFingerprint() = %q, want SHA256: prefix like ssh-keygen -lf", got)
+			}
+		})
+	}
+}
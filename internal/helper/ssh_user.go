@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"os/user"
+	"regexp"
+	"strings"
+)
+
+// same rule as useradd default NAME_REGEX
+var usernamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// ValidateUsername check if username can be created with useradd
+func ValidateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("invalid username %q, use lowercase letters, digits, '_' or '-', max 32 characters", username)
+	}
+
+	return nil
+}
+
+// EnsureUser return the user, create it with its home folder when not exist.
+// Return true when the user is created, require root.
+func EnsureUser(username string) (*user.User, bool, error) {
+	account, err := user.Lookup(username)
+	if err == nil {
+		return account, false, nil
+	}
+
+	var unknownUser user.UnknownUserError
+	if !errors.As(err, &unknownUser) {
+		return nil, false, err
+	}
+
+	err = ValidateUsername(username)
+	if err != nil {
+		return nil, false, err
+	}
+
+	out, err := exec.Command("useradd", "--create-home", "--shell", "/bin/bash", username).CombinedOutput()
+	if err != nil {
+		return nil, false, fmt.Errorf("useradd %s: %w: %s", username, err, strings.TrimSpace(string(out)))
+	}
+
+	account, err = user.Lookup(username)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return account, true, nil
+}
@@ -0,0 +1,30 @@
+package helper
+
+import "testing"
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		wantErr  bool
+	}{
+		{username: "deploy"},
+		{username: "_apt"},
+		{username: "dpanel-agent_2"},
+		{username: "a2345678901234567890123456789012"},
+		{username: "", wantErr: true},
+		{username: "Deploy", wantErr: true},
+		{username: "2deploy", wantErr: true},
+		{username: "-deploy", wantErr: true},
+		{username: "de.ploy", wantErr: true},
+		{username: "../deploy", wantErr: true},
+		{username: "deploy ALL=(ALL) NOPASSWD: ALL", wantErr: true},
+		{username: "deploy\n", wantErr: true},
+		{username: "a23456789012345678901234567890123", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateUsername(tt.username); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateUsername(%q) error = %v, wantErr %v", tt.username, err, tt.wantErr)
+		}
+	}
+}
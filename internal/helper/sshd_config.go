@@ -0,0 +1,98 @@
+package helper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// default path of OpenSSH server config
+const SSHDConfigPath = "/etc/ssh/sshd_config"
+
+// SSHDConfig is global options of sshd_config, keyword in lower case.
+// sshd use the first value of a keyword, options inside Match block are ignored.
+type SSHDConfig map[string]string
+
+// ReadSSHDConfig read sshd_config and the files included by it,
+// relative Include path is resolved from the folder of name, /etc/ssh for the default path
+func ReadSSHDConfig(name string) (SSHDConfig, error) {
+	var config = SSHDConfig{}
+
+	return config, config.read(name, filepath.Dir(name), 0)
+}
+
+// get value of keyword, or defaultValue when keyword not set
+func (c SSHDConfig) Get(keyword string, defaultValue string) string {
+	if value, ok := c[strings.ToLower(keyword)]; ok {
+		return value
+	}
+
+	return defaultValue
+}
+
+// first port of sshd, default 22
+func (c SSHDConfig) Port() string {
+	ports := strings.Fields(c.Get("Port", ""))
+	if len(ports) == 0 {
+		return "22"
+	}
+
+	return ports[0]
+}
+
+func (c SSHDConfig) read(name string, dir string, depth int) error {
+	if depth > 16 {
+		return fmt.Errorf("too many nested Include in %s", name)
+	}
+
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// keyword and value separated by whitespace or =
+		var keyword, value = line, ""
+		if idx := strings.IndexAny(line, " \t="); idx >= 0 {
+			keyword = line[:idx]
+			value = strings.Trim(strings.TrimLeft(line[idx:], " \t="), `"`)
+		}
+		keyword = strings.ToLower(keyword)
+
+		switch keyword {
+		case "match":
+			// the rest of the file is conditional
+			return nil
+		case "include":
+			for _, pattern := range strings.Fields(value) {
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(dir, pattern)
+				}
+
+				files, err := filepath.Glob(pattern)
+				if err != nil {
+					return err
+				}
+
+				for _, file := range files {
+					err = c.read(file, dir, depth+1)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		default:
+			if _, ok := c[keyword]; !ok {
+				c[keyword] = value
+			}
+		}
+	}
+
+	return nil
+}
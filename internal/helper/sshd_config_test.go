@@ -0,0 +1,170 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// write files relative to a temp folder, and return the folder
+func writeSSHDConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	var dir = t.TempDir()
+	for name, content := range files {
+		var path = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.ReplaceAll(content, "{dir}", dir)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestReadSSHDConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  map[string]string
+		// keyword which must not be set
+		unset []string
+	}{
+		{
+			name: "comments and whitespace",
+			files: map[string]string{"sshd_config": `
+# Port 2000
+  Port   2222
+PasswordAuthentication	no
+`},
+			want: map[string]string{"port": "2222", "passwordauthentication": "no"},
+		},
+		{
+			name:  "keyword is case insensitive",
+			files: map[string]string{"sshd_config": "pubkeyAUTHENTICATION yes\n"},
+			want:  map[string]string{"PubkeyAuthentication": "yes"},
+		},
+		{
+			name:  "key=value syntax",
+			files: map[string]string{"sshd_config": "Port=2200\nPasswordAuthentication = no\nAuthorizedKeysFile=\".ssh/keys %h/.ssh/more\"\n"},
+			want:  map[string]string{"port": "2200", "passwordauthentication": "no", "authorizedkeysfile": ".ssh/keys %h/.ssh/more"},
+		},
+		{
+			name:  "first value wins",
+			files: map[string]string{"sshd_config": "Port 2000\nPort 3000\nPasswordAuthentication no\nPasswordAuthentication yes\n"},
+			want:  map[string]string{"port": "2000", "passwordauthentication": "no"},
+		},
+		{
+			name: "stop at Match",
+			files: map[string]string{"sshd_config": `PubkeyAuthentication yes
+Match User deploy
+	PasswordAuthentication yes
+	Port 2000
+`},
+			want:  map[string]string{"pubkeyauthentication": "yes"},
+			unset: []string{"passwordauthentication", "port"},
+		},
+		{
+			name: "relative Include with glob, in file name order",
+			files: map[string]string{
+				"sshd_config":                  "Include sshd_config.d/*.conf\nPort 2000\n",
+				"sshd_config.d/10-port.conf":   "Port 2222\n",
+				"sshd_config.d/20-auth.conf":   "PasswordAuthentication no\nPort 3333\n",
+				"sshd_config.d/ignored.backup": "PubkeyAuthentication no\n",
+			},
+			want:  map[string]string{"port": "2222", "passwordauthentication": "no"},
+			unset: []string{"pubkeyauthentication"},
+		},
+		{
+			name: "absolute Include",
+			files: map[string]string{
+				"sshd_config":     "Include {dir}/other/*.conf\n",
+				"other/a.conf":    "Port 2444\n",
+				"other/b.nomatch": "Port 1\n",
+			},
+			want: map[string]string{"port": "2444"},
+		},
+		{
+			name: "relative Include in included file resolved from main config folder",
+			files: map[string]string{
+				"sshd_config":          "Include sshd_config.d/*.conf\n",
+				"sshd_config.d/a.conf": "Include extra/*.conf\n",
+				"extra/b.conf":         "Port 2555\n",
+				"sshd_config.d/c.conf": "Port 2666\n",
+			},
+			want: map[string]string{"port": "2555"},
+		},
+		{
+			name: "Match in included file only stop the included file",
+			files: map[string]string{
+				"sshd_config":          "Include sshd_config.d/*.conf\nPasswordAuthentication no\n",
+				"sshd_config.d/a.conf": "Port 2777\nMatch Address 10.0.0.0/8\n\tPasswordAuthentication yes\n",
+			},
+			want: map[string]string{"port": "2777", "passwordauthentication": "no"},
+		},
+		{
+			name:  "Include without match",
+			files: map[string]string{"sshd_config": "Include sshd_config.d/*.conf\nPort 2888\n"},
+			want:  map[string]string{"port": "2888"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeSSHDConfigFiles(t, tt.files)
+
+			config, err := ReadSSHDConfig(filepath.Join(dir, "sshd_config"))
+			if err != nil {
+				t.Fatalf("ReadSSHDConfig() error = %v", err)
+			}
+
+			for keyword, want := range tt.want {
+				if got := config.Get(keyword, "<unset>"); got != want {
+					t.Errorf("Get(%q) = %q, want %q", keyword, got, want)
+				}
+			}
+			for _, keyword := range tt.unset {
+				if got, ok := config[keyword]; ok {
+					t.Errorf("%s = %q, want unset", keyword, got)
+				}
+			}
+		})
+	}
+}
+
+func TestReadSSHDConfigIncludeLoop(t *testing.T) {
+	dir := writeSSHDConfigFiles(t, map[string]string{"sshd_config": "Include sshd_config\n"})
+
+	_, err := ReadSSHDConfig(filepath.Join(dir, "sshd_config"))
+	if err == nil || !strings.Contains(err.Error(), "too many nested Include") {
+		t.Fatalf("ReadSSHDConfig() error = %v, want too many nested Include", err)
+	}
+}
+
+func TestReadSSHDConfigMissing(t *testing.T) {
+	_, err := ReadSSHDConfig(filepath.Join(t.TempDir(), "sshd_config"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("ReadSSHDConfig() error = %v, want not exist", err)
+	}
+}
+
+func TestSSHDConfigPort(t *testing.T) {
+	tests := []struct {
+		config SSHDConfig
+		want   string
+	}{
+		{config: SSHDConfig{}, want: "22"},
+		{config: SSHDConfig{"port": "2222"}, want: "2222"},
+		{config: SSHDConfig{"port": "2222 2223"}, want: "2222"},
+		{config: SSHDConfig{"port": ""}, want: "22"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.config.Port(); got != tt.want {
+			t.Errorf("Port() of %v = %q, want %q", tt.config, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package helper
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// folder included by default /etc/sudoers, replaced in tests
+var SudoersDir = "/etc/sudoers.d"
+
+// validate sudoers file with visudo, replaced in tests
+var visudo = func(path string) ([]byte, error) {
+	return exec.Command("visudo", "-c", "-q", "-f", path).CombinedOutput()
+}
+
+// first line of drop-in written by dnocs, only file with this line is removed
+const sudoersHeader = "# " + AuthorizedKeyTag + ": "
+
+// SudoersDropInPath return drop-in file of the user, managed by dnocs
+func SudoersDropInPath(username string) string {
+	return filepath.Join(SudoersDir, "dnocs-"+username)
+}
+
+// WriteSudoersDropIn allow the user to run any command as root without password, as required by dPanel setup.
+// The file is validated with 'visudo -c' before it is installed, invalid file never reach sudoers.d.
+func WriteSudoersDropIn(username string) (string, error) {
+	err := ValidateUsername(username)
+	if err != nil {
+		return "", err
+	}
+
+	var path = SudoersDropInPath(username)
+	var content = fmt.Sprintf("%spasswordless root access of %s for dPanel setup and agent, remove this file to revoke it\n"+
+		"Defaults:%s !requiretty\n"+
+		"%s ALL=(ALL:ALL) NOPASSWD: ALL\n", sudoersHeader, username, username, username)
+
+	// temporary file name contains '.', so sudo ignore it until renamed
+	tmpFile, err := os.CreateTemp(SudoersDir, ".dnocs-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if err := tmpFile.Chmod(0440); err != nil {
+		tmpFile.Close()
+		return "", err
+	}
+
+	if _, err := tmpFile.WriteString(content); err != nil {
+		tmpFile.Close()
+		return "", err
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return "", err
+	}
+
+	out, err := visudo(tmpFile.Name())
+	if err != nil {
+		return "", fmt.Errorf("visudo -c rejected %s: %w: %s", path, err, strings.TrimSpace(string(out)))
+	}
+
+	return path, os.Rename(tmpFile.Name(), path)
+}
+
+// RemoveSudoersDropIn remove drop-in of the user written by dnocs, return true when the file removed.
+// File not written by dnocs is kept.
+func RemoveSudoersDropIn(username string) (bool, error) {
+	if ValidateUsername(username) != nil {
+		return false, nil
+	}
+
+	var path = SudoersDropInPath(username)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var scanner = bufio.NewScanner(file)
+	var managed = scanner.Scan() && strings.HasPrefix(scanner.Text(), sudoersHeader)
+	file.Close()
+
+	if !managed {
+		return false, nil
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,155 @@
+package helper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// sudoers.d in a temp folder, validated by check instead of visudo
+func useTestSudoersDir(t *testing.T, check func(path string) ([]byte, error)) string {
+	t.Helper()
+
+	var dir = t.TempDir()
+	var defaultDir, defaultVisudo = SudoersDir, visudo
+	SudoersDir, visudo = dir, check
+	t.Cleanup(func() {
+		SudoersDir, visudo = defaultDir, defaultVisudo
+	})
+
+	return dir
+}
+
+func TestWriteSudoersDropIn(t *testing.T) {
+	var validated string
+	dir := useTestSudoersDir(t, func(path string) ([]byte, error) {
+		content, err := os.ReadFile(path)
+		validated = string(content)
+		return nil, err
+	})
+
+	path, err := WriteSudoersDropIn("deploy")
+	if err != nil {
+		t.Fatalf("WriteSudoersDropIn() error = %v", err)
+	}
+	if want := filepath.Join(dir, "dnocs-deploy"); path != want {
+		t.Errorf("WriteSudoersDropIn() path = %s, want %s", path, want)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = "# dnocs-managed: passwordless root access of deploy for dPanel setup and agent, remove this file to revoke it\n" +
+		"Defaults:deploy !requiretty\n" +
+		"deploy ALL=(ALL:ALL) NOPASSWD: ALL\n"
+	if string(content) != want {
+		t.Errorf("drop-in content:\n%s\nwant:\n%s", content, want)
+	}
+	if validated != want {
+		t.Errorf("visudo validated:\n%s\nwant:\n%s", validated, want)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0440 {
+		t.Errorf("drop-in permission = %o, want 440", info.Mode().Perm())
+	}
+
+	assertOnlyFiles(t, dir, "dnocs-deploy")
+}
+
+func TestWriteSudoersDropInRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		// visudo is not run for invalid username
+		wantVisudo bool
+	}{
+		{name: "rejected by visudo", username: "deploy", wantVisudo: true},
+		{name: "invalid username", username: "deploy ALL=(ALL) ALL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			dir := useTestSudoersDir(t, func(path string) ([]byte, error) {
+				called = true
+				return []byte("parse error"), errors.New("exit status 1")
+			})
+
+			if _, err := WriteSudoersDropIn(tt.username); err == nil {
+				t.Fatal("WriteSudoersDropIn() error = nil, want error")
+			}
+			if called != tt.wantVisudo {
+				t.Errorf("visudo called = %v, want %v", called, tt.wantVisudo)
+			}
+
+			// rejected file never reach sudoers.d
+			assertOnlyFiles(t, dir)
+		})
+	}
+}
+
+func TestRemoveSudoersDropIn(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		// content of dnocs-<username>, empty means no file
+		content     string
+		wantRemoved bool
+	}{
+		{name: "written by dnocs", username: "deploy", content: "# dnocs-managed: passwordless root access of deploy\ndeploy ALL=(ALL:ALL) NOPASSWD: ALL\n", wantRemoved: true},
+		{name: "written by hand", username: "deploy", content: "deploy ALL=(ALL:ALL) NOPASSWD: ALL\n"},
+		{name: "not exist", username: "deploy"},
+		{name: "invalid username", username: "../sudoers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := useTestSudoersDir(t, nil)
+
+			var path = filepath.Join(dir, "dnocs-"+tt.username)
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0440); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			removed, err := RemoveSudoersDropIn(tt.username)
+			if err != nil {
+				t.Fatalf("RemoveSudoersDropIn() error = %v", err)
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("RemoveSudoersDropIn() = %v, want %v", removed, tt.wantRemoved)
+			}
+
+			_, err = os.Stat(path)
+			if exist := err == nil; exist != (tt.content != "" && !tt.wantRemoved) {
+				t.Errorf("drop-in exist = %v after RemoveSudoersDropIn() = %v", exist, removed)
+			}
+		})
+	}
+}
+
+// check dir contains only the files
+func assertOnlyFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	if !slices.Equal(got, names) {
+		t.Fatalf("files in %s = %q, want %q", dir, got, names)
+	}
+}
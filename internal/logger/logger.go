@@ -2,18 +2,31 @@ package logger
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/charmbracelet/lipgloss"
 )
 
+var (
+	// writer for human message, switched to stderr when command output is JSON or YAML
+	out io.Writer = os.Stdout
+	// writer for error message, keep stdout only for command result
+	errOut io.Writer = os.Stderr
+)
+
+func SetOutput(w io.Writer) {
+	out = w
+}
+
 func Normal(msg string) {
-	fmt.Println(lipgloss.NewStyle().Render(msg))
+	fmt.Fprintln(out, lipgloss.NewStyle().Render(msg))
 }
 
 func Success(msg string) {
-	fmt.Println(lipgloss.NewStyle().Bold(true).Render("✅ " + msg))
+	fmt.Fprintln(out, lipgloss.NewStyle().Bold(true).Render("✅ "+msg))
 }
 
 func Error(msg string) {
-	fmt.Println(lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#ca1414ff")).Render("❗ " + msg))
+	fmt.Fprintln(errOut, lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#ca1414ff")).Render("❗ "+msg))
 }
@@ -0,0 +1,215 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	FormatTable Format = "table"
+	FormatJSON  Format = "json"
+	FormatYAML  Format = "yaml"
+)
+
+// format selected by global --output flag
+var current = FormatTable
+
+func SetFormat(format string) error {
+	switch Format(format) {
+	case FormatTable, FormatJSON, FormatYAML:
+		current = Format(format)
+		return nil
+	}
+
+	return fmt.Errorf("unknown output format %q, use %s, %s or %s", format, FormatTable, FormatJSON, FormatYAML)
+}
+
+func Current() Format {
+	return current
+}
+
+// output is parsed by other program, human message must not be written to stdout
+func IsStructured() bool {
+	return current != FormatTable
+}
+
+// Print write record or list of records to stdout in selected format
+func Print(v any) error {
+	return Fprint(os.Stdout, current, v)
+}
+
+// PrintStream write one record of a stream to stdout, e.g. log followed until interrupted.
+// JSON is written as one line per record, YAML as one document per record,
+// and table as the text from String method of the record.
+func PrintStream(v fmt.Stringer) error {
+	switch current {
+	case FormatJSON:
+		return json.NewEncoder(os.Stdout).Encode(v)
+	case FormatYAML:
+		yamlByte, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(os.Stdout, "---\n%s", yamlByte)
+		return err
+	}
+
+	_, err := fmt.Fprintln(os.Stdout, v.String())
+	return err
+}
+
+// Fprint write record or list of records to w, record is a struct with json and yaml tags.
+// Table format render a struct as aligned key value rows, and a slice as columns.
+func Fprint(w io.Writer, format Format, v any) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(nonNilSlice(v))
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		defer encoder.Close()
+		return encoder.Encode(nonNilSlice(v))
+	}
+
+	return printTable(w, v)
+}
+
+// encode nil slice as empty list, instead of null
+func nonNilSlice(v any) any {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Slice && value.IsNil() {
+		return reflect.MakeSlice(value.Type(), 0, 0).Interface()
+	}
+
+	return v
+}
+
+type column struct {
+	index  int
+	header string
+}
+
+// columns of struct, header from json tag, e.g. ssh_port become SSH PORT
+func columns(recordType reflect.Type) []column {
+	var cols []column
+
+	for i := 0; i < recordType.NumField(); i++ {
+		field := recordType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		cols = append(cols, column{
+			index:  i,
+			header: strings.ToUpper(strings.ReplaceAll(name, "_", " ")),
+		})
+	}
+
+	return cols
+}
+
+func printTable(w io.Writer, v any) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+
+	switch {
+	case value.Kind() == reflect.Struct:
+		for _, col := range columns(value.Type()) {
+			fmt.Fprintf(tw, "%s:\t%s\n", col.header, cell(value.Field(col.index)))
+		}
+	case value.Kind() == reflect.Slice && elemType(value.Type()).Kind() == reflect.Struct:
+		cols := columns(elemType(value.Type()))
+
+		var headers []string
+		for _, col := range cols {
+			headers = append(headers, col.header)
+		}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+
+		for i := 0; i < value.Len(); i++ {
+			row := reflect.Indirect(value.Index(i))
+
+			var cells []string
+			for _, col := range cols {
+				cells = append(cells, cell(row.Field(col.index)))
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+	default:
+		fmt.Fprintln(tw, cell(value))
+	}
+
+	return tw.Flush()
+}
+
+func elemType(sliceType reflect.Type) reflect.Type {
+	elem := sliceType.Elem()
+	if elem.Kind() == reflect.Pointer {
+		return elem.Elem()
+	}
+
+	return elem
+}
+
+// format single value in table cell
+func cell(value reflect.Value) string {
+	if !value.IsValid() {
+		return ""
+	}
+
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		var items []string
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, cell(value.Index(i)))
+		}
+		return strings.Join(items, ", ")
+	case reflect.Map, reflect.Struct:
+		jsonByte, err := json.Marshal(value.Interface())
+		if err != nil {
+			return fmt.Sprintf("%v", value.Interface())
+		}
+		return string(jsonByte)
+	}
+
+	return fmt.Sprintf("%v", value.Interface())
+}
@@ -1,6 +1,7 @@
 package tunnel
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,8 +12,13 @@ type latestResponse struct {
 	TagName string `json:"tag_name"`
 }
 
-func getLatestReleaseVersion() (string, error) {
-	resp, err := http.Get("https://api.github.com/repos/devetek/tuman/releases/latest")
+func getLatestReleaseVersion(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/repos/devetek/tuman/releases/latest", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to make HTTP request: %w", err)
 	}
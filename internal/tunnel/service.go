@@ -0,0 +1,134 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// folder of marijan binary installed by Extract
+var binaryFolder = "/usr/local/bin"
+
+// path of marijan binary
+func (tun *tunnel) BinaryPath() string {
+	return filepath.Join(tun.binFolder, tun.bin)
+}
+
+// path of tunnel config, written by CreateService
+func (tun *tunnel) ConfigPath() string {
+	return filepath.Join(tun.service.folder, tun.service.name)
+}
+
+// name of systemd unit
+func (tun *tunnel) ServiceName() string {
+	return tun.service.serviceName
+}
+
+// state of systemd unit, e.g. active, inactive, failed
+func (tun *tunnel) ServiceState() string {
+	return tun.systemctlQuery("is-active")
+}
+
+// enable state of systemd unit, e.g. enabled, disabled
+func (tun *tunnel) ServiceEnabled() string {
+	return tun.systemctlQuery("is-enabled")
+}
+
+// systemctl print the state even when exit with non zero code, e.g. inactive unit
+func (tun *tunnel) systemctlQuery(action string) string {
+	output, _ := exec.CommandContext(tun.ctx, "systemctl", action, tun.service.serviceName).Output()
+
+	var state = strings.TrimSpace(string(output))
+	if state == "" {
+		return "unknown"
+	}
+
+	return state
+}
+
+func (tun *tunnel) Start() error {
+	return tun.serviceTrigger("start")
+}
+
+func (tun *tunnel) Stop() error {
+	return tun.serviceTrigger("stop")
+}
+
+func (tun *tunnel) Restart() error {
+	return tun.serviceTrigger("restart")
+}
+
+// Logs write journal of systemd unit to w, keep writing new logs until context canceled when follow is true
+func (tun *tunnel) Logs(w io.Writer, lines int, follow bool) error {
+	var args = []string{"--unit", tun.service.serviceName, "--no-pager", "--lines", strconv.Itoa(lines)}
+	if follow {
+		args = append(args, "--follow")
+	}
+
+	cmd := exec.CommandContext(tun.ctx, "journalctl", args...)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil && tun.ctx.Err() != nil {
+		return tun.ctx.Err()
+	}
+
+	return err
+}
+
+// Uninstall stop the service, and remove the files written by CreateService and Extract: its unit, config and marijan binary.
+// Config folder is only removed when empty, other files in it are kept.
+// Every step is executed, return removed paths and all failed steps.
+func (tun *tunnel) Uninstall() ([]string, error) {
+	var removed []string
+	var errs []error
+
+	var serviceExist = tun.IsServiceExist()
+	if serviceExist {
+		// unit may be already stopped or disabled
+		_ = tun.serviceTrigger("stop")
+		_ = tun.serviceTrigger("disable")
+	}
+
+	for _, path := range []string{tun.service.serviceCfg, tun.ConfigPath(), tun.BinaryPath()} {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, path)
+	}
+
+	// remove fail when the folder is not empty or not exist, both are kept as is
+	if err := os.Remove(tun.service.folder); err == nil {
+		removed = append(removed, tun.service.folder)
+	}
+
+	if serviceExist {
+		err := tun.serviceTrigger("daemon-reload")
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// error of systemctl contains its stderr
+func systemctlError(args []string, err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+
+	return fmt.Errorf("systemctl %s: %w", strings.Join(args, " "), err)
+}
@@ -0,0 +1,150 @@
+package tunnel
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tunnel installed in a temp folder, systemctl calls are recorded and fail for actions in failures
+func newTestTunnel(t *testing.T, failures ...string) (*tunnel, *[]string) {
+	t.Helper()
+
+	var dir = t.TempDir()
+	for _, folder := range []string{"unit", "opt/tunnel", "bin"} {
+		if err := os.MkdirAll(filepath.Join(dir, folder), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var calls []string
+	tun := NewTunnel()
+	tun.service.serviceCfg = filepath.Join(dir, "unit", "dpanel-tunnel.service")
+	tun.service.folder = filepath.Join(dir, "opt/tunnel")
+	tun.binFolder = filepath.Join(dir, "bin")
+	tun.systemctl = func(args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		for _, action := range failures {
+			if args[0] == action {
+				return nil, errors.New("exit status 1")
+			}
+		}
+		return nil, nil
+	}
+
+	return tun, &calls
+}
+
+func writeTestFile(t *testing.T, path string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	tun, calls := newTestTunnel(t)
+	writeTestFile(t, tun.service.serviceCfg)
+	writeTestFile(t, tun.ConfigPath())
+	writeTestFile(t, tun.BinaryPath())
+	// files not written by dnocs
+	writeTestFile(t, filepath.Join(tun.binFolder, "other"))
+
+	removed, err := tun.Uninstall()
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+
+	var want = []string{tun.service.serviceCfg, tun.ConfigPath(), tun.BinaryPath(), tun.service.folder}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("Uninstall() removed = %q, want %q", removed, want)
+	}
+	for _, path := range want {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s exist after Uninstall()", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tun.binFolder, "other")); err != nil {
+		t.Errorf("other file in binary folder removed: %v", err)
+	}
+
+	if want := []string{"stop dpanel-tunnel", "disable dpanel-tunnel", "daemon-reload"}; !reflect.DeepEqual(*calls, want) {
+		t.Errorf("systemctl calls = %q, want %q", *calls, want)
+	}
+}
+
+// config folder with other files is kept
+func TestUninstallKeepOtherFiles(t *testing.T) {
+	tun, _ := newTestTunnel(t)
+	writeTestFile(t, tun.service.serviceCfg)
+	writeTestFile(t, tun.ConfigPath())
+	writeTestFile(t, filepath.Join(tun.service.folder, "notes.txt"))
+
+	removed, err := tun.Uninstall()
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+
+	if want := []string{tun.service.serviceCfg, tun.ConfigPath()}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("Uninstall() removed = %q, want %q", removed, want)
+	}
+	if _, err := os.Stat(filepath.Join(tun.service.folder, "notes.txt")); err != nil {
+		t.Errorf("other file in config folder removed: %v", err)
+	}
+}
+
+func TestUninstallNotInstalled(t *testing.T) {
+	tun, calls := newTestTunnel(t)
+
+	removed, err := tun.Uninstall()
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+
+	// empty config folder is removed as well
+	if want := []string{tun.service.folder}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("Uninstall() removed = %q, want %q", removed, want)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("systemctl calls = %q, want none", *calls)
+	}
+}
+
+// every step is executed, and all failed steps are returned
+func TestUninstallErrors(t *testing.T) {
+	tun, calls := newTestTunnel(t, "stop", "daemon-reload")
+	writeTestFile(t, tun.service.serviceCfg)
+	writeTestFile(t, tun.BinaryPath())
+
+	// not empty folder in place of the config can not be removed, also by root
+	if err := os.MkdirAll(filepath.Join(tun.ConfigPath(), "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := tun.Uninstall()
+	if err == nil {
+		t.Fatal("Uninstall() error = nil, want error")
+	}
+
+	if want := []string{tun.service.serviceCfg, tun.BinaryPath()}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("Uninstall() removed = %q, want %q", removed, want)
+	}
+
+	for _, part := range []string{tun.ConfigPath(), "systemctl daemon-reload"} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("Uninstall() error = %q, want it to contain %q", err, part)
+		}
+	}
+	// failed stop is ignored, the unit may be already stopped
+	if strings.Contains(err.Error(), "systemctl stop") {
+		t.Errorf("Uninstall() error = %q, want failed stop ignored", err)
+	}
+
+	if want := []string{"stop dpanel-tunnel", "disable dpanel-tunnel", "daemon-reload"}; !reflect.DeepEqual(*calls, want) {
+		t.Errorf("systemctl calls = %q, want %q", *calls, want)
+	}
+}
@@ -3,6 +3,7 @@ package tunnel
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -47,11 +48,17 @@ type tunnelService struct {
 }
 
 type tunnel struct {
+	ctx     context.Context
 	baseURL string
 	version string
 	bin     string
 	server  tunnelServer  // tunnel server (any SSH server)
 	service tunnelService // tunnel service in this server
+
+	// folder of marijan binary
+	binFolder string
+	// run systemctl, replaced in tests
+	systemctl func(args ...string) ([]byte, error)
 }
 
 func NewTunnel() *tunnel {
@@ -67,6 +74,7 @@ func NewTunnel() *tunnel {
 	}
 
 	client := &tunnel{
+		ctx:     context.Background(),
 		baseURL: binaryDownloadURL,
 		version: binaryVersion,
 		bin:     "marijan",
@@ -81,11 +89,20 @@ func NewTunnel() *tunnel {
 			serviceName: serviceName,
 			configs:     []marijan.Config{},
 		},
+		binFolder: binaryFolder,
+		systemctl: runSystemctl,
 	}
 
 	return client
 }
 
+// set context to cancel download and version check
+func (tun *tunnel) SetContext(ctx context.Context) *tunnel {
+	tun.ctx = ctx
+
+	return tun
+}
+
 func (tun *tunnel) SetConfig(configs []marijan.Config) *tunnel {
 	tun.service.configs = configs
 
@@ -100,13 +117,13 @@ func (tun *tunnel) GetConfig() []marijan.Config {
 	// 1. Read the file content
 	fileBytes, err := os.ReadFile(finalPath)
 	if err != nil {
-		fmt.Printf("Error reading file: %v\n", err)
+		logger.Error(fmt.Sprintf("Error reading file: %v", err))
 		return configs
 	}
 
 	err = json.Unmarshal(fileBytes, &configs)
 	if err != nil {
-		fmt.Printf("Error unmarshaling JSON: %v\n", err)
+		logger.Error(fmt.Sprintf("Error unmarshaling JSON: %v", err))
 		return configs
 	}
 
@@ -129,7 +146,7 @@ func (tun *tunnel) GetCurrentVersion() string {
 }
 
 func (tun *tunnel) GetNewVersion() string {
-	newVersion, err := getLatestReleaseVersion()
+	newVersion, err := getLatestReleaseVersion(tun.ctx)
 	if err != nil {
 		return ""
 	}
@@ -195,7 +212,12 @@ func (tun *tunnel) Download() error {
 	defer out.Close()
 
 	// Get the data
-	resp, err := http.Get(source)
+	req, err := http.NewRequestWithContext(tun.ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to make HTTP request to %s: %w", source, err)
 	}
@@ -284,7 +306,7 @@ func (tun *tunnel) Extract() error {
 	logger.Success(fmt.Sprintf("📑 Copy Marijan to /usr/local/bin for %s (%s).", runtime.GOOS, runtime.GOARCH))
 
 	// Construct the new path for the file in the destination directory
-	destinationFilePath := filepath.Join("/usr/local/bin", tun.bin)
+	destinationFilePath := tun.BinaryPath()
 
 	// Move the file using os.Rename
 	err = os.Rename(destination, destinationFilePath)
@@ -303,7 +325,9 @@ func (tun *tunnel) Extract() error {
 
 // check if systemd service exist
 func (tun *tunnel) IsServiceExist() bool {
-	return true
+	_, err := os.Stat(tun.service.serviceCfg)
+
+	return err == nil
 }
 
 func (tun *tunnel) CreateService() error {
@@ -412,12 +436,14 @@ WantedBy=multi-user.target
 	}
 
 	// enable systemd service
-	if tun.serviceTrigger("enable") != nil {
+	err = tun.serviceTrigger("enable")
+	if err != nil {
 		return err
 	}
 
 	// start systemd service
-	if tun.serviceTrigger("start") != nil {
+	err = tun.serviceTrigger("start")
+	if err != nil {
 		return err
 	}
 
@@ -425,19 +451,24 @@ WantedBy=multi-user.target
 }
 
 func (tun *tunnel) serviceTrigger(action string) error {
-	// trigger systemd service
-	words := strings.Split(fmt.Sprintf("%s %s", action, tun.service.serviceName), " ")
-	systemdCMD := exec.Command("systemctl", words...)
-
+	// trigger systemd service, daemon-reload is not related to a unit
+	words := []string{action}
+	if action != "daemon-reload" {
+		words = append(words, tun.service.serviceName)
+	}
 	// TODO: trap output and stream real-time
-	_, err := systemdCMD.Output()
+	_, err := tun.systemctl(words...)
 	if err != nil {
-		return err
+		return systemctlError(words, err)
 	}
 
 	return nil
 }
 
+func runSystemctl(args ...string) ([]byte, error) {
+	return exec.Command("systemctl", args...).Output()
+}
+
 func (tun *tunnel) successMessage() {
 	logger.Success("⭐ If you like Marijan, please give it a star on GitHub: https://github.com/devetek/tuman")
 }